@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"prometheus-webhook/internal/delivery"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AlertHandler 处理 POST /alert，根据当前配置的路由树将告警分发到多个接收器
+type AlertHandler struct {
+	registry *Registry
+}
+
+func NewAlertHandler(registry *Registry) *AlertHandler {
+	return &AlertHandler{
+		registry: registry,
+	}
+}
+
+func (ah *AlertHandler) Handle(c *gin.Context) {
+	current := ah.registry.Current()
+	if current.Router == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "没有配置路由"})
+		return
+	}
+
+	webhookData, ok := bindAlertmanagerWebhook(c)
+	if !ok {
+		return
+	}
+
+	routed := current.Router.Route(webhookData)
+	if len(routed) == 0 {
+		slog.Warn("告警组没有匹配任何路由, 告警被忽略", "group_key", webhookData.GroupKey, "alerts", len(webhookData.Alerts))
+		c.JSON(http.StatusOK, gin.H{
+			"message": "没有匹配的路由",
+			"alerts":  len(webhookData.Alerts),
+		})
+		return
+	}
+
+	status := http.StatusOK
+	results := make([]gin.H, 0, len(routed))
+	for _, r := range routed {
+		handler := current.Handlers[r.Receiver]
+		result := gin.H{
+			"receiver": r.Receiver,
+			"alerts":   len(r.Webhook.Alerts),
+		}
+		if err := handler.Process(r.Webhook); errors.Is(err, errDuplicate) {
+			result["duplicate"] = true
+		} else if err != nil {
+			result["error"] = err.Error()
+			var batchErr *delivery.BatchError
+			if errors.As(err, &batchErr) {
+				result["results"] = batchErr.Results
+			}
+			// 500 优先于 502 和 503，它们都会让 Alertmanager 重试
+			if status != http.StatusInternalServerError {
+				status = errorStatus(err)
+			}
+		} else if handler.Async() {
+			result["queued"] = true
+			if status == http.StatusOK {
+				status = http.StatusAccepted
+			}
+		}
+		results = append(results, result)
+	}
+
+	if status >= http.StatusInternalServerError {
+		c.JSON(status, gin.H{
+			"error":   "部分接收器处理失败",
+			"results": results,
+		})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"message": "告警处理成功",
+		"results": results,
+	})
+}
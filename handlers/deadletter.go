@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"prometheus-webhook/internal/outbox"
+	"prometheus-webhook/internal/redact"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeadLetterHandler 提供死信的查看、重新发送和删除接口
+type DeadLetterHandler struct {
+	store    *outbox.Store
+	registry *Registry
+}
+
+func NewDeadLetterHandler(store *outbox.Store, registry *Registry) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		store:    store,
+		registry: registry,
+	}
+}
+
+// List 处理 GET /api/v1/deadletters，可以通过 ?receiver= 按接收器过滤
+func (dh *DeadLetterHandler) List(c *gin.Context) {
+	deadLetters, err := dh.store.DeadLetters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取死信失败: " + err.Error()})
+		return
+	}
+
+	receiver := c.Query("receiver")
+	result := make([]outbox.DeadLetter, 0, len(deadLetters))
+	for _, deadLetter := range deadLetters {
+		if receiver != "" && deadLetter.Receiver != receiver {
+			continue
+		}
+		result = append(result, redactDeadLetter(deadLetter))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total":       len(result),
+		"deadletters": result,
+	})
+}
+
+// Replay 处理 POST /api/v1/deadletters/:id/replay，同步重新发送一条死信
+func (dh *DeadLetterHandler) Replay(c *gin.Context) {
+	deadLetter, ok := dh.lookup(c)
+	if !ok {
+		return
+	}
+
+	handler, ok := dh.registry.Current().Handlers[deadLetter.Receiver]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "接收器 " + deadLetter.Receiver + " 不存在"})
+		return
+	}
+
+	if err := handler.Resend(deadLetter); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":      "重新发送失败: " + err.Error(),
+			"deadletter": redactDeadLetter(*deadLetter),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "重新发送成功",
+		"id":       deadLetter.ID,
+		"receiver": deadLetter.Receiver,
+	})
+}
+
+// Delete 处理 DELETE /api/v1/deadletters/:id
+func (dh *DeadLetterHandler) Delete(c *gin.Context) {
+	id := c.Param("id")
+	if err := dh.store.DeleteDeadLetter(id); err != nil {
+		if errors.Is(err, outbox.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "死信 " + id + " 不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除死信失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "死信已删除",
+		"id":      id,
+	})
+}
+
+func (dh *DeadLetterHandler) lookup(c *gin.Context) (*outbox.DeadLetter, bool) {
+	id := c.Param("id")
+	deadLetter, err := dh.store.DeadLetter(id)
+	if err != nil {
+		if errors.Is(err, outbox.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "死信 " + id + " 不存在"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取死信失败: " + err.Error()})
+		return nil, false
+	}
+	return deadLetter, true
+}
+
+// redactDeadLetter 返回对请求头中的认证信息脱敏后的死信，用于接口响应
+func redactDeadLetter(deadLetter outbox.DeadLetter) outbox.DeadLetter {
+	deadLetter.Headers = redact.Headers(deadLetter.Headers)
+	return deadLetter
+}
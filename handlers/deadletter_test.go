@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"prometheus-webhook/internal/outbox"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deadLetterFixture 准备一个带死信接口的路由，以及 ops 和 dba 两个接收器各一条死信
+type deadLetterFixture struct {
+	store  *outbox.Store
+	sender *testSender
+	router *gin.Engine
+	ids    map[string]string // 接收器名称 -> 死信 ID
+}
+
+func newDeadLetterFixture(t *testing.T) *deadLetterFixture {
+	t.Helper()
+	f := &deadLetterFixture{
+		store:  openTestOutbox(t),
+		sender: &testSender{},
+		ids:    make(map[string]string),
+	}
+
+	receiver := testReceiver(t)
+	receiver.Name = "ops"
+	receivers := map[string]*WebhookHandler{
+		"ops": newTestHandler(t, receiver, f.sender, f.store),
+	}
+	for _, name := range []string{"ops", "dba"} {
+		deadLetter := outbox.DeadLetter{Entry: outbox.Entry{Receiver: name, Message: "告警 " + name}, Error: "boom"}
+		if err := f.store.Put(&deadLetter.Entry); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.store.Bury(&deadLetter); err != nil {
+			t.Fatal(err)
+		}
+		f.ids[name] = deadLetter.ID
+	}
+
+	dh := NewDeadLetterHandler(f.store, NewRegistry(&Receivers{Handlers: receivers}))
+	f.router = gin.New()
+	f.router.GET("/api/v1/deadletters", dh.List)
+	f.router.POST("/api/v1/deadletters/:id/replay", dh.Replay)
+	f.router.DELETE("/api/v1/deadletters/:id", dh.Delete)
+	return f
+}
+
+func (f *deadLetterFixture) do(method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func (f *deadLetterFixture) list(t *testing.T, query string) []outbox.DeadLetter {
+	t.Helper()
+	w := f.do(http.MethodGet, "/api/v1/deadletters"+query)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/v1/deadletters%s status = %d, body = %s", query, w.Code, w.Body)
+	}
+	var resp struct {
+		Total       int                 `json:"total"`
+		DeadLetters []outbox.DeadLetter `json:"deadletters"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != len(resp.DeadLetters) {
+		t.Errorf("total = %d, want %d", resp.Total, len(resp.DeadLetters))
+	}
+	return resp.DeadLetters
+}
+
+func TestDeadLetterList(t *testing.T) {
+	f := newDeadLetterFixture(t)
+
+	all := f.list(t, "")
+	if len(all) != 2 || all[0].Receiver != "ops" || all[1].Receiver != "dba" || all[0].Error != "boom" {
+		t.Errorf("deadletters = %+v, want both in write order", all)
+	}
+	if filtered := f.list(t, "?receiver=dba"); len(filtered) != 1 || filtered[0].ID != f.ids["dba"] {
+		t.Errorf("deadletters?receiver=dba = %+v", filtered)
+	}
+	if filtered := f.list(t, "?receiver=none"); len(filtered) != 0 {
+		t.Errorf("deadletters?receiver=none = %+v, want empty", filtered)
+	}
+}
+
+func TestDeadLetterReplay(t *testing.T) {
+	f := newDeadLetterFixture(t)
+
+	// 重新发送失败时更新错误信息和重放次数，保留死信
+	f.sender.err = errors.New("still failing")
+	if w := f.do(http.MethodPost, "/api/v1/deadletters/"+f.ids["ops"]+"/replay"); w.Code != http.StatusBadGateway {
+		t.Fatalf("replay status = %d, want 502, body = %s", w.Code, w.Body)
+	}
+	deadLetter, err := f.store.DeadLetter(f.ids["ops"])
+	if err != nil || deadLetter.Replays != 1 || deadLetter.Error != "still failing" {
+		t.Errorf("deadletter after failed replay = %+v, %v", deadLetter, err)
+	}
+
+	f.sender.err = nil
+	if w := f.do(http.MethodPost, "/api/v1/deadletters/"+f.ids["ops"]+"/replay"); w.Code != http.StatusOK {
+		t.Fatalf("replay status = %d, body = %s", w.Code, w.Body)
+	}
+	if got := f.sender.sent(); len(got) != 2 || got[1] != "告警 ops" {
+		t.Errorf("sent = %q, want the dead letter message", got)
+	}
+	if _, err := f.store.DeadLetter(f.ids["ops"]); !errors.Is(err, outbox.ErrNotFound) {
+		t.Errorf("deadletter after successful replay error = %v, want ErrNotFound", err)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+	}{
+		{name: "接收器已不存在", id: f.ids["dba"]},
+		{name: "死信不存在", id: "missing"},
+	}
+	for _, tt := range tests {
+		if w := f.do(http.MethodPost, "/api/v1/deadletters/"+tt.id+"/replay"); w.Code != http.StatusNotFound {
+			t.Errorf("%s: replay status = %d, want 404", tt.name, w.Code)
+		}
+	}
+}
+
+func TestDeadLetterDelete(t *testing.T) {
+	f := newDeadLetterFixture(t)
+
+	if w := f.do(http.MethodDelete, "/api/v1/deadletters/"+f.ids["dba"]); w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d, body = %s", w.Code, w.Body)
+	}
+	if all := f.list(t, ""); len(all) != 1 || all[0].Receiver != "ops" {
+		t.Errorf("deadletters after delete = %+v", all)
+	}
+	if w := f.do(http.MethodDelete, "/api/v1/deadletters/"+f.ids["dba"]); w.Code != http.StatusNotFound {
+		t.Errorf("second delete status = %d, want 404", w.Code)
+	}
+	if len(f.sender.sent()) != 0 {
+		t.Error("delete must not send the message")
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"prometheus-webhook/models"
+	"prometheus-webhook/services"
+)
+
+// errDuplicate 通知中的所有告警都没有状态变化，不需要发送
+var errDuplicate = errors.New("重复通知已忽略")
+
+// dedupMark 发送成功或入队后需要记录的告警状态
+type dedupMark struct {
+	key    string
+	status string
+}
+
+// deduplicate 过滤掉状态没有变化的告警，返回剩余告警组成的通知以及是否全部是持续触发的提醒
+func (wh *WebhookHandler) deduplicate(webhookData models.AlertmanagerWebhook) (models.AlertmanagerWebhook, []dedupMark, bool) {
+	if wh.dedup == nil {
+		return webhookData, nil, false
+	}
+
+	var alerts []models.Alert
+	var marks []dedupMark
+	reminder := true
+	for _, alert := range webhookData.Alerts {
+		key := dedupKey(webhookData.GroupKey, alert)
+		forward, isReminder := wh.dedup.Check(key, alert.Status)
+		if !forward {
+			slog.Debug("告警没有状态变化, 忽略", "receiver", wh.receiver.Name, "group_key", webhookData.GroupKey,
+				"fingerprint", alert.Fingerprint, "status", alert.Status)
+			continue
+		}
+		alerts = append(alerts, alert)
+		marks = append(marks, dedupMark{key: key, status: alert.Status})
+		reminder = reminder && isReminder
+	}
+
+	switch len(alerts) {
+	case 0:
+		return webhookData, nil, false
+	case len(webhookData.Alerts):
+		return webhookData, marks, reminder
+	default:
+		return services.SubWebhook(webhookData, alerts), marks, reminder
+	}
+}
+
+// InheritDedup 在重新加载配置后沿用旧处理器的去重记录，避免已发送的告警被再次发送
+// 去重配置发生变化时不沿用
+func (wh *WebhookHandler) InheritDedup(old *WebhookHandler) {
+	if wh.dedup == nil || old.dedup == nil {
+		return
+	}
+	if wh.receiver.Dedup.Window != old.receiver.Dedup.Window || wh.receiver.Dedup.ReminderInterval != old.receiver.Dedup.ReminderInterval {
+		return
+	}
+	wh.dedup = old.dedup
+}
+
+// markSent 记录已发送或已入队的告警状态
+func (wh *WebhookHandler) markSent(marks []dedupMark) {
+	if wh.dedup == nil {
+		return
+	}
+	for _, m := range marks {
+		wh.dedup.Mark(m.key, m.status)
+	}
+}
+
+// dedupKey 使用告警组和 fingerprint 标识一条告警，没有 fingerprint 时使用排序后的标签
+func dedupKey(groupKey string, alert models.Alert) string {
+	if alert.Fingerprint != "" {
+		return groupKey + "|" + alert.Fingerprint
+	}
+
+	names := make([]string, 0, len(alert.Labels))
+	for name := range alert.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(groupKey)
+	for _, name := range names {
+		b.WriteString("|" + name + "=" + alert.Labels[name])
+	}
+	return b.String()
+}
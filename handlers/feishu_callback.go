@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"prometheus-webhook/internal/alertmanager"
+	"prometheus-webhook/internal/metrics"
+	"prometheus-webhook/internal/provider/feishu"
+	"prometheus-webhook/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// silenceTimeout 创建静默的超时时间，飞书要求在 3 秒内响应回调
+const silenceTimeout = 2 * time.Second
+
+// maxSilenceDuration 卡片按钮允许创建的最长静默
+const maxSilenceDuration = 7 * 24 * time.Hour
+
+// 卡片按钮的操作
+const (
+	cardActionAck     = "ack"
+	cardActionSilence = "silence"
+)
+
+// FeishuCallbackHandler 处理飞书应用机器人的卡片按钮回调 POST /webhook/:name/callback，
+// 值班人员可以在群里确认告警或在 Alertmanager 中创建静默，卡片随后更新为操作人和操作结果
+type FeishuCallbackHandler struct {
+	registry     *Registry
+	alertmanager *alertmanager.Client
+}
+
+func NewFeishuCallbackHandler(registry *Registry) *FeishuCallbackHandler {
+	return &FeishuCallbackHandler{
+		registry:     registry,
+		alertmanager: alertmanager.NewClient(silenceTimeout),
+	}
+}
+
+func (fh *FeishuCallbackHandler) Handle(c *gin.Context) {
+	name := c.Param("name")
+	current := fh.registry.Current()
+	handler, ok := current.Handlers[name]
+	if !ok || handler.receiver.Type != models.ReceiverTypeFeishu || handler.receiver.FeishuApp.VerificationToken == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "接收器 " + name + " 没有启用飞书卡片回调"})
+		return
+	}
+	cfg := handler.receiver.FeishuApp
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取请求失败"})
+		return
+	}
+	action, err := feishu.ParseCallback(cfg, body)
+	if err != nil {
+		slog.Warn("飞书卡片回调无效", "receiver", name, "error", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, feishu.ErrInvalidToken) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if action.Challenge != "" {
+		c.JSON(http.StatusOK, gin.H{"challenge": action.Challenge})
+		return
+	}
+
+	var note string
+	now := time.Now().In(current.Templates.Location())
+	operator := "<at id=" + action.OperatorID + "></at>"
+	switch action.Value.Action {
+	case cardActionAck:
+		note = fmt.Sprintf("✅ %s 已于 %s 确认告警", operator, now.Format("01-02 15:04"))
+	case cardActionSilence:
+		var silenceID string
+		var endsAt time.Time
+		silenceID, endsAt, err = fh.silence(c.Request.Context(), fh.alertmanagerURL(handler, action), action, now)
+		if err == nil {
+			note = fmt.Sprintf("🔕 %s 已静默告警至 %s (静默 ID: %s)", operator, endsAt.In(now.Location()).Format("01-02 15:04"), silenceID)
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的卡片操作: " + action.Value.Action})
+		return
+	}
+
+	if err != nil {
+		metrics.CardActions.WithLabelValues(name, action.Value.Action, "failure").Inc()
+		slog.Error("处理飞书卡片操作失败", "receiver", name, "action", action.Value.Action, "operator", action.OperatorID, "error", err)
+		// 返回 200 让飞书展示提示，非 200 的响应会被飞书当作回调地址异常
+		c.JSON(http.StatusOK, feishu.CallbackResponse(action, nil, "操作失败: "+err.Error(), false))
+		return
+	}
+	metrics.CardActions.WithLabelValues(name, action.Value.Action, "success").Inc()
+	slog.Info("处理飞书卡片操作", "receiver", name, "action", action.Value.Action, "operator", action.OperatorID, "message_id", action.MessageID)
+
+	var card json.RawMessage
+	service, ok := handler.messageHandler.(*feishu.Service)
+	if ok {
+		card, ok = service.AnnotateCard(name, action.MessageID, note)
+	}
+	if !ok {
+		slog.Debug("没有找到卡片内容, 不更新卡片", "receiver", name, "message_id", action.MessageID)
+	}
+	c.JSON(http.StatusOK, feishu.CallbackResponse(action, card, "操作成功", true))
+}
+
+// InheritCards 在重新加载配置后沿用旧处理器中飞书应用机器人保存的卡片
+func (wh *WebhookHandler) InheritCards(old *WebhookHandler) {
+	next, ok := wh.messageHandler.(*feishu.Service)
+	if !ok {
+		return
+	}
+	if prev, ok := old.messageHandler.(*feishu.Service); ok {
+		next.InheritCards(prev)
+	}
+}
+
+// alertmanagerURL 返回创建静默使用的 Alertmanager 地址: 优先使用配置的地址，其次使用发送卡片时保存在服务端的 externalURL，
+// 从不使用回调内容中的地址
+func (fh *FeishuCallbackHandler) alertmanagerURL(handler *WebhookHandler, action *feishu.CardAction) string {
+	if url := strings.TrimSpace(handler.receiver.FeishuApp.AlertmanagerURL); url != "" {
+		return url
+	}
+	if service, ok := handler.messageHandler.(*feishu.Service); ok {
+		if url, ok := service.CardExternalURL(handler.receiver.Name, action.MessageID); ok {
+			return url
+		}
+	}
+	return ""
+}
+
+// silence 按按钮中的标签和时长在 Alertmanager 中创建静默，返回静默 ID 和结束时间
+func (fh *FeishuCallbackHandler) silence(ctx context.Context, alertmanagerURL string, action *feishu.CardAction, now time.Time) (string, time.Time, error) {
+	value := action.Value
+	duration, err := time.ParseDuration(value.Duration)
+	if err != nil || duration <= 0 || duration > maxSilenceDuration {
+		return "", time.Time{}, fmt.Errorf("静默时长 '%s' 无效", value.Duration)
+	}
+	if len(value.Labels) == 0 {
+		return "", time.Time{}, errors.New("按钮中没有告警标签")
+	}
+	if alertmanagerURL == "" {
+		return "", time.Time{}, errors.New("没有配置 alertmanager_url, 卡片中也没有保存告警的 externalURL")
+	}
+
+	endsAt := now.Add(duration)
+	silenceID, err := fh.alertmanager.CreateSilence(ctx, alertmanagerURL, models.Silence{
+		Matchers:  alertmanager.Matchers(value.Labels),
+		StartsAt:  now,
+		EndsAt:    endsAt,
+		CreatedBy: "feishu:" + action.OperatorID,
+		Comment:   fmt.Sprintf("通过飞书告警卡片静默 %s", value.Duration),
+	})
+	return silenceID, endsAt, err
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"prometheus-webhook/internal/provider/feishu"
+	"prometheus-webhook/internal/provider/providertest"
+	"prometheus-webhook/models"
+	"prometheus-webhook/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newCallbackRouter 返回处理 feishu-app 接收器卡片回调的路由，sender 为接收器发送消息使用的服务
+func newCallbackRouter(t *testing.T, cfg models.FeishuAppConfig, sender MessageHandler) *gin.Engine {
+	t.Helper()
+	receiver := testReceiver(t)
+	receiver.Name = "feishu-app"
+	receiver.Type = models.ReceiverTypeFeishu
+	receiver.FeishuApp = cfg
+	templates := services.NewTemplateService(time.UTC)
+	registry := NewRegistry(&Receivers{
+		Config:    models.Config{Receivers: []models.Receiver{receiver}},
+		Handlers:  map[string]*WebhookHandler{receiver.Name: newTestHandler(t, receiver, sender, nil)},
+		Templates: templates,
+	})
+
+	router := gin.New()
+	router.POST("/webhook/:name/callback", NewFeishuCallbackHandler(registry).Handle)
+	return router
+}
+
+func postCallback(router *gin.Engine, name, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook/"+name+"/callback", strings.NewReader(body)))
+	return w
+}
+
+func silenceCallback(duration string) string {
+	return `{"schema":"2.0","header":{"token":"verification-token"},"event":{"operator":{"open_id":"ou_1"},
+		"action":{"value":{"action":"silence","duration":"` + duration + `","labels":{"alertname":"Disk","instance":"db1"}}},
+		"context":{"open_message_id":"om_1"}}}`
+}
+
+func TestFeishuCallbackSilence(t *testing.T) {
+	am := providertest.NewServer(t, providertest.Response{Body: `{"silenceID":"s-1"}`})
+	router := newCallbackRouter(t, models.FeishuAppConfig{VerificationToken: "verification-token", AlertmanagerURL: am.URL}, &testSender{})
+
+	w := postCallback(router, "feishu-app", silenceCallback("2h"))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"success"`) {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	requests := am.Requests()
+	if len(requests) != 1 || requests[0].Path != "/api/v2/silences" {
+		t.Fatalf("alertmanager requests = %+v", requests)
+	}
+	var silence models.Silence
+	if err := json.Unmarshal([]byte(requests[0].Body), &silence); err != nil {
+		t.Fatal(err)
+	}
+	if len(silence.Matchers) != 2 || silence.Matchers[0].Name != "alertname" || silence.CreatedBy != "feishu:ou_1" {
+		t.Errorf("silence = %+v", silence)
+	}
+	if d := silence.EndsAt.Sub(silence.StartsAt); d != 2*time.Hour {
+		t.Errorf("silence duration = %s, want 2h", d)
+	}
+
+	// 超过上限的时长不会创建静默，返回 200 让飞书展示错误提示
+	w = postCallback(router, "feishu-app", silenceCallback("720h"))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"error"`) {
+		t.Errorf("status = %d, body = %s, want an error toast", w.Code, w.Body)
+	}
+	if n := len(am.Requests()); n != 1 {
+		t.Errorf("alertmanager requests = %d, want 1", n)
+	}
+}
+
+func TestFeishuCallbackRejected(t *testing.T) {
+	router := newCallbackRouter(t, models.FeishuAppConfig{VerificationToken: "verification-token"}, &testSender{})
+
+	tests := []struct {
+		name     string
+		receiver string
+		body     string
+		want     int
+	}{
+		{"token 错误", "feishu-app", strings.Replace(silenceCallback("1h"), "verification-token", "wrong", 1), http.StatusUnauthorized},
+		{"无效的 JSON", "feishu-app", `{`, http.StatusBadRequest},
+		{"不支持的操作", "feishu-app", `{"token":"verification-token","action":{"value":{"action":"delete"}}}`, http.StatusBadRequest},
+		{"接收器不存在", "missing", silenceCallback("1h"), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := postCallback(router, tt.receiver, tt.body); w.Code != tt.want {
+				t.Errorf("status = %d, body = %s, want %d", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+
+	w := postCallback(router, "feishu-app", `{"type":"url_verification","token":"verification-token","challenge":"abc"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"challenge":"abc"`) {
+		t.Errorf("url_verification status = %d, body = %s", w.Code, w.Body)
+	}
+}
+
+func TestFeishuCallbackIgnoresPayloadAlertmanagerURL(t *testing.T) {
+	am := providertest.NewServer(t, providertest.Response{Body: `{"silenceID":"s-1"}`})
+	router := newCallbackRouter(t, models.FeishuAppConfig{VerificationToken: "verification-token"}, &testSender{})
+
+	// 回调内容可以被伪造，其中的地址不能用来创建静默
+	body := strings.Replace(silenceCallback("1h"), `"duration"`, `"alertmanager_url":"`+am.URL+`","duration"`, 1)
+	w := postCallback(router, "feishu-app", body)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"error"`) {
+		t.Errorf("status = %d, body = %s, want an error toast", w.Code, w.Body)
+	}
+	if n := len(am.Requests()); n != 0 {
+		t.Errorf("alertmanager requests = %d, want 0", n)
+	}
+}
+
+func TestFeishuCallbackSilenceUsesCardExternalURL(t *testing.T) {
+	am := providertest.NewServer(t, providertest.Response{Body: `{"silenceID":"s-1"}`})
+	// 同一个响应同时作为获取令牌和发送消息的响应
+	openAPI := providertest.NewServer(t, providertest.Response{
+		Body: `{"code":0,"msg":"ok","tenant_access_token":"t-1","expire":7200,"data":{"message_id":"om_1"}}`,
+	})
+	cfg := models.FeishuAppConfig{AppID: "cli_test", AppSecret: "secret", ChatID: "oc_1", APIBaseURL: openAPI.URL, VerificationToken: "verification-token"}
+	service := feishu.NewService(nil)
+	router := newCallbackRouter(t, cfg, service)
+
+	// 没有配置 alertmanager_url 时使用发送卡片时保存的 externalURL
+	provider := providertest.Provider(openAPI.URL)
+	provider.ReceiverName = "feishu-app"
+	provider.FeishuApp = cfg
+	message := `{"msg_type":"interactive","fingerprint":"fp1","status":"firing","external_url":"` + am.URL + `","card":{"elements":[]}}`
+	if err := service.SendMessage(provider, message); err != nil {
+		t.Fatal(err)
+	}
+	w := postCallback(router, "feishu-app", silenceCallback("1h"))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"success"`) {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if requests := am.Requests(); len(requests) != 1 || requests[0].Path != "/api/v2/silences" {
+		t.Errorf("alertmanager requests = %+v, want one silence", requests)
+	}
+	for _, request := range openAPI.Requests() {
+		if strings.Contains(request.Body, "external_url") {
+			t.Errorf("external_url was sent to Feishu: %s", request.Body)
+		}
+	}
+}
@@ -2,42 +2,89 @@ package handlers
 
 import (
 	"net/http"
-	"prometheus-webhook/models"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// BuildInfo 构建时通过 -ldflags 注入的版本信息
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
 type HealthHandler struct {
-	config models.Config
+	build    BuildInfo
+	registry *Registry
 }
 
-func NewHealthHandler(config models.Config) *HealthHandler {
-	return &HealthHandler{config: config}
+func NewHealthHandler(build BuildInfo, registry *Registry) *HealthHandler {
+	return &HealthHandler{
+		build:    build,
+		registry: registry,
+	}
 }
 
 func (hh *HealthHandler) HealthCheck(c *gin.Context) {
-	config := hh.config
+	config := hh.registry.Current().Config
 
+	// 只返回接收器名称和类型，webhook 地址中包含 access_token 等凭据，不能对外暴露
 	enabledWebhooks := make(map[string]string)
-	if config.Webhooks.Feishu.Enable {
-		enabledWebhooks["feishu"] = config.Webhooks.Feishu.WebhookURL
-	}
-	if config.Webhooks.Dingding.Enable {
-		enabledWebhooks["dingding"] = config.Webhooks.Dingding.WebhookURL
-	}
-	if config.Webhooks.Weixin.Enable {
-		enabledWebhooks["weixin"] = config.Webhooks.Weixin.WebhookURL
+	for _, receiver := range config.Receivers {
+		enabledWebhooks[receiver.Name] = receiver.Type
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":           "healthy",
 		"timestamp":        time.Now().Format(time.RFC3339),
-		"version":          "1.0.0",
+		"version":          hh.build.Version,
+		"build":            hh.build,
 		"enabled_webhooks": enabledWebhooks,
 	})
 }
 
+// Livez 处理 GET /livez，进程能够响应请求即视为存活
+func (hh *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"version": hh.build.Version,
+	})
+}
+
+// Readyz 处理 GET /readyz，任一接收器检查失败时返回 503
+func (hh *HealthHandler) Readyz(c *gin.Context) {
+	current := hh.registry.Current()
+	ready := true
+	receivers := make([]gin.H, 0, len(current.Config.Receivers))
+	for _, receiver := range current.Config.Receivers {
+		result := gin.H{
+			"name": receiver.Name,
+			"type": receiver.Type,
+		}
+		if handler, ok := current.Handlers[receiver.Name]; ok {
+			if problems := handler.Check(current.Config.Readiness); len(problems) > 0 {
+				ready = false
+				result["errors"] = problems
+			}
+		}
+		result["ready"] = result["errors"] == nil
+		receivers = append(receivers, result)
+	}
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"status":    status,
+		"timestamp": time.Now().Format(time.RFC3339),
+		"version":   hh.build.Version,
+		"receivers": receivers,
+	})
+}
+
 func NewServer(port string, timeout time.Duration, handler http.Handler) *http.Server {
 	return &http.Server{
 		Addr:         ":" + port,
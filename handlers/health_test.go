@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"prometheus-webhook/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readyz 请求 /readyz 并返回状态码和各接收器的检查结果
+func readyz(t *testing.T, hh *HealthHandler) (int, []map[string]interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	hh.Readyz(c)
+
+	var body struct {
+		Receivers []map[string]interface{} `json:"receivers"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid response %s: %v", w.Body, err)
+	}
+	return w.Code, body.Receivers
+}
+
+func TestReadyz(t *testing.T) {
+	receiver := testReceiver(t)
+	sender := &testSender{}
+	wh := newTestHandler(t, receiver, sender, nil)
+	config := models.Config{
+		Receivers: []models.Receiver{receiver},
+		Readiness: models.ReadinessConfig{QueueThreshold: 0.9, DeliveryWindow: time.Minute},
+	}
+	hh := NewHealthHandler(BuildInfo{Version: "test"}, NewRegistry(&Receivers{Config: config, Handlers: map[string]*WebhookHandler{receiver.Name: wh}}))
+
+	if code, receivers := readyz(t, hh); code != http.StatusOK || receivers[0]["ready"] != true {
+		t.Fatalf("status = %d, receivers = %v, want ready", code, receivers)
+	}
+
+	// 窗口内的发送全部失败时未就绪
+	sender.err = errors.New("boom")
+	postJSON(wh.Handle, testWebhook("firing", "Disk"))
+	code, receivers := readyz(t, hh)
+	if code != http.StatusServiceUnavailable || receivers[0]["ready"] != false || receivers[0]["errors"] == nil {
+		t.Fatalf("status = %d, receivers = %v, want not ready", code, receivers)
+	}
+
+	// 之后有一次发送成功即恢复就绪
+	sender.err = nil
+	postJSON(wh.Handle, testWebhook("firing", "Disk"))
+	if code, receivers := readyz(t, hh); code != http.StatusOK {
+		t.Errorf("status = %d, receivers = %v, want ready after a successful delivery", code, receivers)
+	}
+}
+
+func TestReadyzBrokenTemplate(t *testing.T) {
+	receiver := testReceiver(t)
+	receiver.Template = writeTemplate(t, "broken", `{{ .status `)
+	wh := newTestHandler(t, receiver, &testSender{}, nil)
+	config := models.Config{Receivers: []models.Receiver{receiver}}
+	hh := NewHealthHandler(BuildInfo{}, NewRegistry(&Receivers{Config: config, Handlers: map[string]*WebhookHandler{receiver.Name: wh}}))
+
+	if code, receivers := readyz(t, hh); code != http.StatusServiceUnavailable || receivers[0]["errors"] == nil {
+		t.Errorf("status = %d, receivers = %v, want not ready", code, receivers)
+	}
+}
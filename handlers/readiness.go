@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"prometheus-webhook/models"
+)
+
+// deliveryStats 记录接收器最近一次发送成功和失败的时间，用于就绪检查
+type deliveryStats struct {
+	mu          sync.Mutex
+	lastSuccess time.Time
+	lastFailure time.Time
+}
+
+func (s *deliveryStats) record(success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if success {
+		s.lastSuccess = time.Now()
+	} else {
+		s.lastFailure = time.Now()
+	}
+}
+
+// failing 返回 window 内是否有发送并且全部失败
+func (s *deliveryStats) failing(window time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	since := time.Now().Add(-window)
+	return s.lastFailure.After(since) && !s.lastSuccess.After(since)
+}
+
+// Check 检查接收器能否正常处理告警，返回发现的问题，全部正常时返回 nil
+// 检查项包括: 模板能否解析、异步队列是否接近饱和，以及配置了 delivery_window 时最近的发送是否全部失败
+func (wh *WebhookHandler) Check(cfg models.ReadinessConfig) []string {
+	var problems []string
+	for _, templatePath := range wh.templatePaths() {
+		if err := wh.checkTemplate(templatePath); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if wh.queue != nil {
+		length, capacity := wh.queue.Len(), wh.queue.Cap()
+		if float64(length) >= cfg.QueueThreshold*float64(capacity) {
+			problems = append(problems, fmt.Sprintf("发送队列接近饱和: %d/%d", length, capacity))
+		}
+	}
+
+	if cfg.DeliveryWindow > 0 && wh.stats.failing(cfg.DeliveryWindow) {
+		problems = append(problems, fmt.Sprintf("最近 %s 内的发送全部失败", cfg.DeliveryWindow))
+	}
+	return problems
+}
+
+// CheckTemplates 检查接收器使用的所有模板能否解析
+func (wh *WebhookHandler) CheckTemplates() error {
+	var errs []error
+	for _, templatePath := range wh.templatePaths() {
+		if err := wh.checkTemplate(templatePath); err != nil {
+			errs = append(errs, fmt.Errorf("接收器 '%s': %w", wh.receiver.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// templatePaths 返回接收器使用的模板文件，启用 storm 时包括汇总模板
+func (wh *WebhookHandler) templatePaths() []string {
+	paths := []string{wh.receiver.Template}
+	if wh.receiver.Storm.Threshold > 0 {
+		paths = append(paths, wh.receiver.Storm.Template)
+	}
+	return paths
+}
+
+// checkTemplate 通过 GetTemplate 检查模板能否使用: 尚未加载的模板文件会被读取、解析，
+// 并检查是否定义了 <文件名>_message 子模板；已加载的模板直接使用缓存，即使文件之后被修改为无法解析的内容。
+// 只检查能否解析，不会使用告警数据渲染模板
+func (wh *WebhookHandler) checkTemplate(templatePath string) error {
+	if _, err := wh.templateService.GetTemplate(templatePath); err != nil {
+		return fmt.Errorf("模板 %s 加载失败: %w", templatePath, err)
+	}
+	return nil
+}
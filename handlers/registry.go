@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"prometheus-webhook/models"
+	"prometheus-webhook/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Receivers 一次配置加载生成的接收器处理器和路由树
+type Receivers struct {
+	Config   models.Config
+	Handlers map[string]*WebhookHandler
+	// Router 基于标签的路由树，没有配置 routes 时为 nil
+	Router *services.AlertRouter
+	// Templates 这些接收器共用的模板服务
+	Templates *services.TemplateService
+}
+
+// CheckTemplates 检查所有接收器的模板能否解析，用于在重新加载配置前发现模板错误
+func (r *Receivers) CheckTemplates() error {
+	var errs []error
+	for _, receiver := range r.Config.Receivers {
+		if err := r.Handlers[receiver.Name].CheckTemplates(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close 停止检查模板文件，并等待所有接收器队列中的消息发送完成
+func (r *Receivers) Close() {
+	if r.Templates != nil {
+		r.Templates.Close()
+	}
+	for _, handler := range r.Handlers {
+		handler.Close()
+	}
+}
+
+// Registry 保存当前生效的接收器和路由树，配置重新加载时整体替换，
+// 正在处理的请求继续使用替换前的接收器
+type Registry struct {
+	current atomic.Pointer[Receivers]
+}
+
+func NewRegistry(receivers *Receivers) *Registry {
+	r := &Registry{}
+	r.current.Store(receivers)
+	return r
+}
+
+// Current 返回当前生效的接收器
+func (r *Registry) Current() *Receivers {
+	return r.current.Load()
+}
+
+// Swap 替换当前生效的接收器，返回被替换的接收器
+func (r *Registry) Swap(receivers *Receivers) *Receivers {
+	return r.current.Swap(receivers)
+}
+
+// Handle 处理 POST /webhook/:name，将请求交给当前配置中的同名接收器
+func (r *Registry) Handle(c *gin.Context) {
+	name := c.Param("name")
+	handler, ok := r.Current().Handlers[name]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "接收器 " + name + " 不存在"})
+		return
+	}
+	handler.Handle(c)
+}
+
+// HandleLegacy 返回旧版 /feishu, /dingding, /weixin 端点的处理函数，
+// 只有当前配置中启用了对应的旧版 webhooks 条目时才会处理
+func (r *Registry) HandleLegacy(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		current := r.Current()
+		handler, ok := current.Handlers[name]
+		if !ok || !legacyEnabled(current.Config, name) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "端点 /" + name + " 未启用"})
+			return
+		}
+		handler.Handle(c)
+	}
+}
+
+func legacyEnabled(config models.Config, name string) bool {
+	switch name {
+	case models.ReceiverTypeFeishu:
+		return config.Webhooks.Feishu.Enable
+	case models.ReceiverTypeDingding:
+		return config.Webhooks.Dingding.Enable
+	case models.ReceiverTypeWeixin:
+		return config.Webhooks.Weixin.Enable
+	default:
+		return false
+	}
+}
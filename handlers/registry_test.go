@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"prometheus-webhook/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRegistryHandleLegacy(t *testing.T) {
+	sender := &testSender{}
+	var config models.Config
+	config.Webhooks.Feishu.Enable = true
+	// weixin 是通过 receivers 配置的同名接收器，旧版端点没有启用
+	receivers := map[string]*WebhookHandler{}
+	for _, name := range []string{"feishu", "weixin"} {
+		receiver := testReceiver(t)
+		receiver.Name = name
+		receivers[name] = newTestHandler(t, receiver, sender, nil)
+	}
+	registry := NewRegistry(&Receivers{Config: config, Handlers: receivers})
+
+	router := gin.New()
+	router.POST("/webhook/:name", registry.Handle)
+	for _, name := range []string{"feishu", "dingding", "weixin"} {
+		router.POST("/"+name, registry.HandleLegacy(name))
+	}
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/feishu", http.StatusOK},
+		{"/dingding", http.StatusNotFound},
+		{"/weixin", http.StatusNotFound},
+		{"/webhook/weixin", http.StatusOK},
+		{"/webhook/missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(testWebhook("firing", "Disk"))))
+		if w.Code != tt.want {
+			t.Errorf("POST %s status = %d, body = %s, want %d", tt.path, w.Code, w.Body, tt.want)
+		}
+	}
+	if n := len(sender.sent()); n != 2 {
+		t.Errorf("sent %d messages, want 2", n)
+	}
+}
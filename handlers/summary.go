@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"sort"
+
+	"prometheus-webhook/models"
+)
+
+// severityRank 摘要中告警的排序优先级，未知级别排在最后
+var severityRank = map[string]int{
+	"critical": 0,
+	"error":    1,
+	"warning":  2,
+	"info":     3,
+}
+
+// summarizeAlerts 统计告警风暴中的告警，供汇总模板使用
+// top 中的告警按 触发中优先、级别从高到低、开始时间从早到晚 排序，最多 topK 条
+func summarizeAlerts(webhookData models.AlertmanagerWebhook, topK int) map[string]interface{} {
+	var firing, resolved int
+	for _, alert := range webhookData.Alerts {
+		if alert.Status == "resolved" {
+			resolved++
+		} else {
+			firing++
+		}
+	}
+
+	alerts := make([]models.Alert, len(webhookData.Alerts))
+	copy(alerts, webhookData.Alerts)
+	sort.SliceStable(alerts, func(i, j int) bool {
+		a, b := alerts[i], alerts[j]
+		if (a.Status == "resolved") != (b.Status == "resolved") {
+			return a.Status != "resolved"
+		}
+		if ra, rb := rankOf(a.Labels["severity"]), rankOf(b.Labels["severity"]); ra != rb {
+			return ra < rb
+		}
+		return a.StartsAt.Before(b.StartsAt)
+	})
+	if topK > len(alerts) {
+		topK = len(alerts)
+	}
+
+	var top []map[string]interface{}
+	for _, alert := range alerts[:topK] {
+		top = append(top, alertTemplateData(alert))
+	}
+
+	return map[string]interface{}{
+		"total":       len(webhookData.Alerts),
+		"firing":      firing,
+		"resolved":    resolved,
+		"byAlertname": countByLabel(webhookData.Alerts, "alertname"),
+		"bySeverity":  countByLabel(webhookData.Alerts, "severity"),
+		"byNamespace": countByLabel(webhookData.Alerts, "namespace"),
+		"top":         top,
+		"omitted":     len(webhookData.Alerts) - topK,
+	}
+}
+
+// countByLabel 按标签值统计告警数，按数量从多到少排序，缺少该标签的告警计入 "-"
+func countByLabel(alerts []models.Alert, label string) []map[string]interface{} {
+	counts := make(map[string]int)
+	for _, alert := range alerts {
+		value := alert.Labels[label]
+		if value == "" {
+			value = "-"
+		}
+		counts[value]++
+	}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	result := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		result = append(result, map[string]interface{}{
+			"name":  name,
+			"count": counts[name],
+		})
+	}
+	return result
+}
+
+func rankOf(severity string) int {
+	if rank, ok := severityRank[severity]; ok {
+		return rank
+	}
+	return len(severityRank)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"prometheus-webhook/models"
+)
+
+func stormAlert(name, status, severity, namespace string, startsAt time.Time) models.Alert {
+	labels := map[string]string{"alertname": name}
+	if severity != "" {
+		labels["severity"] = severity
+	}
+	if namespace != "" {
+		labels["namespace"] = namespace
+	}
+	return models.Alert{Status: status, Labels: labels, StartsAt: startsAt, Fingerprint: name + "-" + status}
+}
+
+// topNames 返回摘要中 top 告警的 "告警名/状态"
+func topNames(summary map[string]interface{}) []string {
+	var names []string
+	top, _ := summary["top"].([]map[string]interface{})
+	for _, alert := range top {
+		labels := alert["Labels"].(map[string]string)
+		names = append(names, labels["alertname"]+"/"+alert["Status"].(string))
+	}
+	return names
+}
+
+func TestSummarizeAlerts(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	alerts := []models.Alert{
+		stormAlert("DiskFull", "firing", "warning", "db", t0),
+		stormAlert("PodCrash", "resolved", "critical", "web", t0),
+		stormAlert("NodeDown", "firing", "critical", "", t0.Add(time.Minute)),
+		stormAlert("HighLatency", "firing", "", "web", t0),
+		stormAlert("APIDown", "firing", "critical", "web", t0.Add(-time.Minute)),
+		stormAlert("DiskFull", "firing", "info", "db", t0),
+	}
+
+	tests := []struct {
+		name        string
+		topK        int
+		wantTop     []string
+		wantOmitted int
+	}{
+		{
+			name: "触发中优先，级别从高到低，开始时间从早到晚",
+			topK: 10,
+			wantTop: []string{
+				"APIDown/firing", "NodeDown/firing", "DiskFull/firing", "DiskFull/firing",
+				"HighLatency/firing", "PodCrash/resolved",
+			},
+		},
+		{
+			name:        "只保留前 topK 条",
+			topK:        2,
+			wantTop:     []string{"APIDown/firing", "NodeDown/firing"},
+			wantOmitted: 4,
+		},
+		{
+			name:        "topK 为 0",
+			topK:        0,
+			wantOmitted: 6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := summarizeAlerts(models.AlertmanagerWebhook{Alerts: alerts}, tt.topK)
+			if got := topNames(summary); !reflect.DeepEqual(got, tt.wantTop) {
+				t.Errorf("top = %v, want %v", got, tt.wantTop)
+			}
+			if summary["omitted"] != tt.wantOmitted {
+				t.Errorf("omitted = %v, want %d", summary["omitted"], tt.wantOmitted)
+			}
+			if summary["total"] != 6 || summary["firing"] != 5 || summary["resolved"] != 1 {
+				t.Errorf("total/firing/resolved = %v/%v/%v, want 6/5/1", summary["total"], summary["firing"], summary["resolved"])
+			}
+		})
+	}
+	if alerts[0].Labels["alertname"] != "DiskFull" || alerts[4].Labels["alertname"] != "APIDown" {
+		t.Error("summarizeAlerts() reordered the caller's alerts")
+	}
+}
+
+func TestCountByLabel(t *testing.T) {
+	t0 := time.Now()
+	alerts := []models.Alert{
+		stormAlert("DiskFull", "firing", "warning", "db", t0),
+		stormAlert("PodCrash", "firing", "critical", "web", t0),
+		stormAlert("NodeDown", "firing", "critical", "", t0),
+		stormAlert("DiskFull", "firing", "warning", "web", t0),
+		stormAlert("APIDown", "firing", "critical", "", t0),
+	}
+	tests := []struct {
+		label string
+		want  []map[string]interface{}
+	}{
+		{
+			// 数量相同时按名称排序
+			label: "alertname",
+			want: []map[string]interface{}{
+				{"name": "DiskFull", "count": 2},
+				{"name": "APIDown", "count": 1},
+				{"name": "NodeDown", "count": 1},
+				{"name": "PodCrash", "count": 1},
+			},
+		},
+		{
+			label: "severity",
+			want: []map[string]interface{}{
+				{"name": "critical", "count": 3},
+				{"name": "warning", "count": 2},
+			},
+		},
+		{
+			// 缺少标签的告警计入 "-"
+			label: "namespace",
+			want: []map[string]interface{}{
+				{"name": "-", "count": 2},
+				{"name": "web", "count": 2},
+				{"name": "db", "count": 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := countByLabel(alerts, tt.label); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("countByLabel(%s) = %v, want %v", tt.label, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+
+	"prometheus-webhook/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TemplateHandler 提供模板加载状态的查询接口
+type TemplateHandler struct {
+	registry *Registry
+}
+
+func NewTemplateHandler(registry *Registry) *TemplateHandler {
+	return &TemplateHandler{
+		registry: registry,
+	}
+}
+
+// templateResult 模板加载状态以及使用该模板的接收器
+type templateResult struct {
+	services.TemplateStatus
+	Receivers []string `json:"receivers"`
+}
+
+// List 处理 GET /api/v1/templates，返回当前配置中每个模板的加载状态和最近一次加载错误
+func (th *TemplateHandler) List(c *gin.Context) {
+	current := th.registry.Current()
+
+	var paths []string
+	users := make(map[string][]string)
+	for _, receiver := range current.Config.Receivers {
+		for _, templatePath := range current.Handlers[receiver.Name].templatePaths() {
+			if _, ok := users[templatePath]; !ok {
+				paths = append(paths, templatePath)
+			}
+			users[templatePath] = append(users[templatePath], receiver.Name)
+		}
+	}
+
+	healthy := true
+	results := make([]templateResult, 0, len(paths))
+	for _, templatePath := range paths {
+		// 尚未使用过的模板在这里加载，以便报告错误
+		current.Templates.GetTemplate(templatePath)
+		status := current.Templates.Status(templatePath)
+		if !status.Loaded || status.LastError != "" {
+			healthy = false
+		}
+		results = append(results, templateResult{TemplateStatus: status, Receivers: users[templatePath]})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"healthy":   healthy,
+		"templates": results,
+	})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"prometheus-webhook/models"
+	"prometheus-webhook/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTemplateListReportsLastError(t *testing.T) {
+	receiver := testReceiver(t)
+	sender := &testSender{}
+	templates := services.NewTemplateService(time.UTC)
+	wh, err := NewWebhookHandler(sender, receiver, templates, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(wh.Close)
+	registry := NewRegistry(&Receivers{
+		Config:    models.Config{Receivers: []models.Receiver{receiver}},
+		Handlers:  map[string]*WebhookHandler{receiver.Name: wh},
+		Templates: templates,
+	})
+	router := gin.New()
+	router.GET("/api/v1/templates", NewTemplateHandler(registry).List)
+
+	if w := postJSON(wh.Handle, testWebhook("firing", "Disk")); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+
+	// 模板修改后无法解析
+	if err := os.WriteFile(receiver.Template, []byte(`{{ define "test_message" }}{{ .status {{ end }}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	go templates.Watch(10 * time.Millisecond)
+	t.Cleanup(templates.Close)
+
+	var body struct {
+		Healthy   bool `json:"healthy"`
+		Templates []struct {
+			services.TemplateStatus
+			Receivers []string `json:"receivers"`
+		} `json:"templates"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/templates", nil))
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("invalid response %s: %v", w.Body, err)
+		}
+		if len(body.Templates) == 1 && body.Templates[0].LastError != "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("templates = %s, want LastError after a broken edit", w.Body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if body.Healthy || !body.Templates[0].Loaded || body.Templates[0].Receivers[0] != "test" {
+		t.Errorf("response = %+v", body)
+	}
+
+	// 继续使用最近一次加载成功的模板发送
+	if w := postJSON(wh.Handle, testWebhook("resolved", "Disk")); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if got := sender.sent(); len(got) != 2 || got[1] != "resolved Disk" {
+		t.Errorf("sent = %q", got)
+	}
+}
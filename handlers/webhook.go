@@ -2,115 +2,465 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"path/filepath"
 	"strings"
+	"time"
 
+	"prometheus-webhook/internal/dedup"
+	"prometheus-webhook/internal/delivery"
+	"prometheus-webhook/internal/logging"
+	"prometheus-webhook/internal/metrics"
+	"prometheus-webhook/internal/outbox"
+	"prometheus-webhook/internal/queue"
 	"prometheus-webhook/models"
 	"prometheus-webhook/services"
 
 	"github.com/gin-gonic/gin"
 )
 
+// maxLoggedBodySize debug 日志中记录的原始请求体的最大字节数
+const maxLoggedBodySize = 4 << 10
+
 // MessageHandler 定义了发送消息服务的通用接口
 type MessageHandler interface {
 	SendMessage(providerConfig models.WebhookProvider, message string) error
 }
 
+// MentionMerger 支持 @ 提醒的发送服务实现该接口，渲染后的消息在发送前合并需要 @ 的人；
+// byFingerprint 为每条告警需要 @ 的人，all 为所有告警合并后的结果
+type MentionMerger interface {
+	MergeMentions(message string, all models.Mentions, byFingerprint map[string]models.Mentions) (string, error)
+}
+
 type WebhookHandler struct {
 	messageHandler  MessageHandler
-	providerConfig  models.WebhookProvider
+	receiver        models.Receiver
 	templateService *services.TemplateService
+	queue           *queue.Queue
+	outbox          *outbox.Store
+	dedup           *dedup.Cache
+	mentions        *services.MentionResolver
+	stats           deliveryStats
 }
 
-func NewWebhookHandler(handler MessageHandler, providerConfig models.WebhookProvider, templateService *services.TemplateService) *WebhookHandler {
-	return &WebhookHandler{
+// NewWebhookHandler 创建接收器的处理器，接收器启用队列时同时启动发送协程池
+// store 为 nil 时不持久化消息
+func NewWebhookHandler(handler MessageHandler, receiver models.Receiver, templateService *services.TemplateService, store *outbox.Store) (*WebhookHandler, error) {
+	mentions, err := services.NewMentionResolver(receiver.Mentions)
+	if err != nil {
+		return nil, fmt.Errorf("创建提醒规则失败: %w", err)
+	}
+	wh := &WebhookHandler{
 		messageHandler:  handler,
-		providerConfig:  providerConfig,
+		receiver:        receiver,
 		templateService: templateService,
+		outbox:          store,
+		mentions:        mentions,
+	}
+	if receiver.Queue.Enabled() {
+		wh.queue = queue.New(receiver.Name, receiver.Queue.Size, receiver.Queue.Workers, receiver.Queue.Overflow, func(job queue.Job) {
+			if err := wh.deliver(job); err != nil {
+				wh.bury(job, err)
+			}
+		})
+		wh.queue.OnDrop = wh.discard
+	}
+	if receiver.Dedup.Enabled() {
+		wh.dedup = dedup.New(receiver.Dedup.Window, receiver.Dedup.ReminderInterval)
+	}
+	return wh, nil
+}
+
+// Async 返回当前接收器是否通过队列异步发送
+func (wh *WebhookHandler) Async() bool {
+	return wh.queue != nil
+}
+
+// Close 等待队列中的消息发送完成
+func (wh *WebhookHandler) Close() {
+	if wh.queue != nil {
+		wh.queue.Close()
 	}
 }
 
 func (wh *WebhookHandler) Handle(c *gin.Context) {
-	var webhookData models.AlertmanagerWebhook
+	webhookData, ok := bindAlertmanagerWebhook(c)
+	if !ok {
+		return
+	}
 
-	bodyBytes, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		log.Printf("读取请求体失败: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无法读取请求"})
+	if err := wh.Process(webhookData); err != nil {
+		if errors.Is(err, errDuplicate) {
+			c.JSON(http.StatusOK, gin.H{
+				"message":  err.Error(),
+				"receiver": wh.receiver.Name,
+				"alerts":   len(webhookData.Alerts),
+			})
+			return
+		}
+		response := gin.H{"error": err.Error()}
+		var batchErr *delivery.BatchError
+		if errors.As(err, &batchErr) {
+			response["receiver"] = wh.receiver.Name
+			response["results"] = batchErr.Results
+		}
+		c.JSON(errorStatus(err), response)
 		return
 	}
-	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-	log.Printf("接收到原始告警 Webhook: %s", string(bodyBytes))
 
-	if err := c.BindJSON(&webhookData); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的JSON数据"})
+	if wh.Async() {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":  "告警已加入发送队列",
+			"receiver": wh.receiver.Name,
+			"alerts":   len(webhookData.Alerts),
+		})
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "告警处理成功",
+		"receiver": wh.receiver.Name,
+		"alerts":   len(webhookData.Alerts),
+	})
+}
+
+// Process 渲染模板并将告警发送到当前接收器，启用队列时只入队不等待发送结果
+func (wh *WebhookHandler) Process(webhookData models.AlertmanagerWebhook) error {
 	status := webhookData.Status
 	if status == "" && len(webhookData.Alerts) > 0 {
 		status = webhookData.Alerts[0].Status
 	}
-	log.Printf("接收到告警: %d 条告警, 状态: %s", len(webhookData.Alerts), status)
+	metrics.NotificationsReceived.WithLabelValues(wh.receiver.Name).Inc()
+	slog.Info("接收到告警", "receiver", wh.receiver.Name, "group_key", webhookData.GroupKey, "alerts", len(webhookData.Alerts), "status", status)
+	for _, alert := range webhookData.Alerts {
+		metrics.AlertsReceived.WithLabelValues(wh.receiver.Name, alert.Status).Inc()
+		slog.Debug("告警详情", "receiver", wh.receiver.Name, "group_key", webhookData.GroupKey, "fingerprint", alert.Fingerprint,
+			"alertname", alert.Labels["alertname"], "status", alert.Status)
+	}
 
-	// 渲染模板
-	var messageBuf bytes.Buffer
-	data := wh.prepareTemplateData(webhookData)
+	// 过滤掉状态没有变化的重复通知
+	received := len(webhookData.Alerts)
+	webhookData, marks, reminder := wh.deduplicate(webhookData)
+	if wh.dedup != nil {
+		metrics.AlertsSuppressed.WithLabelValues(wh.receiver.Name).Add(float64(received - len(marks)))
+		if len(marks) == 0 {
+			slog.Info("告警组没有状态变化, 忽略重复通知", "receiver", wh.receiver.Name, "group_key", webhookData.GroupKey)
+			return errDuplicate
+		}
+		if len(marks) < received {
+			slog.Info("部分告警没有状态变化, 只发送其余告警", "receiver", wh.receiver.Name, "group_key", webhookData.GroupKey,
+				"suppressed", received-len(marks), "forwarded", len(marks))
+		}
+	}
 
-	tmpl, err := wh.templateService.GetTemplate(wh.providerConfig.Template)
+	// 渲染模板，告警数超过风暴阈值时改用汇总模板只发送一条摘要
+	mentions, alertMentions := wh.mentions.ResolveAll(webhookData.Alerts)
+	data := wh.prepareTemplateData(webhookData, alertMentions)
+	data["reminder"] = reminder
+	data["mentions"] = mentions
+	templatePath := wh.receiver.Template
+	if storm := wh.receiver.Storm; storm.Threshold > 0 && len(webhookData.Alerts) > storm.Threshold {
+		slog.Warn("告警数超过风暴阈值, 使用汇总模板", "receiver", wh.receiver.Name, "group_key", webhookData.GroupKey,
+			"alerts", len(webhookData.Alerts), "threshold", storm.Threshold, "template", storm.Template)
+		templatePath = storm.Template
+		data["summary"] = summarizeAlerts(webhookData, storm.TopK)
+		metrics.StormSummaries.WithLabelValues(wh.receiver.Name).Inc()
+	}
+
+	message, err := wh.render(templatePath, data)
 	if err != nil {
-		log.Printf("获取模板 '%s' 失败: %v", wh.providerConfig.Template, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "模板加载失败"})
+		metrics.RenderFailures.WithLabelValues(wh.receiver.Name).Inc()
+		return err
+	}
+
+	// 将需要 @ 的人合并到消息中，例如钉钉的 at、企业微信的 mentioned_list
+	if merger, ok := wh.messageHandler.(MentionMerger); ok && !mentions.Empty() {
+		message, err = merger.MergeMentions(message, mentions, alertMentions)
+		if err != nil {
+			slog.Error("合并提醒对象失败", "receiver", wh.receiver.Name, "group_key", webhookData.GroupKey, "error", err)
+			metrics.RenderFailures.WithLabelValues(wh.receiver.Name).Inc()
+			return fmt.Errorf("模板渲染失败")
+		}
+	}
+
+	providerConfig := wh.receiver.WebhookProvider
+	if len(providerConfig.Generic.Headers) > 0 {
+		headers, err := wh.renderHeaders(data)
+		if err != nil {
+			slog.Error("请求头模板渲染失败", "receiver", wh.receiver.Name, "group_key", webhookData.GroupKey, "error", err)
+			metrics.RenderFailures.WithLabelValues(wh.receiver.Name).Inc()
+			return fmt.Errorf("模板渲染失败")
+		}
+		providerConfig.Generic.Headers = headers
+	}
+
+	job := queue.Job{
+		Receiver:       wh.receiver.Name,
+		GroupKey:       webhookData.GroupKey,
+		ProviderConfig: providerConfig,
+		Message:        message,
+		EnqueuedAt:     time.Now(),
+	}
+	if err := wh.persist(&job); err != nil {
+		slog.Error("写入发件箱失败", "receiver", wh.receiver.Name, "group_key", job.GroupKey, "error", err)
+		return fmt.Errorf("消息持久化失败")
+	}
+
+	if wh.queue != nil {
+		if err := wh.queue.Submit(job); err != nil {
+			slog.Warn("消息入队失败", "receiver", wh.receiver.Name, "group_key", job.GroupKey, "error", err)
+			// 入队失败时由 Alertmanager 重试，不需要保留在发件箱中
+			wh.discard(job)
+			return fmt.Errorf("消息入队失败: %w", err)
+		}
+		wh.markSent(marks)
+		return nil
+	}
+
+	// 同步发送失败时只返回 5xx，由 Alertmanager 负责重试，不保留在发件箱中也不移入死信，
+	// 否则 Alertmanager 重试成功后会留下重复的死信
+	if err := wh.deliver(job); err != nil {
+		wh.discard(job)
+		// 部分消息发送失败时保留每条消息的结果，在响应中返回
+		var batchErr *delivery.BatchError
+		if errors.As(err, &batchErr) {
+			return fmt.Errorf("发送消息失败: %w", batchErr)
+		}
+		return fmt.Errorf("发送消息失败")
+	}
+	wh.markSent(marks)
+	return nil
+}
+
+// render 使用模板文件中的 <文件名>_message 子模板渲染消息
+func (wh *WebhookHandler) render(templatePath string, data map[string]interface{}) (string, error) {
+	tmpl, err := wh.templateService.GetTemplate(templatePath)
+	if err != nil {
+		slog.Error("获取模板失败", "receiver", wh.receiver.Name, "template", templatePath, "error", err)
+		return "", fmt.Errorf("模板加载失败")
+	}
+
+	var messageBuf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&messageBuf, services.MessageTemplateName(templatePath), data); err != nil {
+		slog.Error("模板渲染失败", "receiver", wh.receiver.Name, "template", templatePath, "error", err)
+		return "", fmt.Errorf("模板渲染失败")
+	}
+	return messageBuf.String(), nil
+}
+
+// Replay 重新发送发件箱中上次运行未发送成功的消息
+func (wh *WebhookHandler) Replay(entry outbox.Entry) {
+	providerConfig := wh.receiver.WebhookProvider
+	if entry.Headers != nil {
+		providerConfig.Generic.Headers = entry.Headers
+	}
+	job := queue.Job{
+		ID:             entry.ID,
+		Receiver:       entry.Receiver,
+		GroupKey:       entry.GroupKey,
+		ProviderConfig: providerConfig,
+		Message:        entry.Message,
+		EnqueuedAt:     time.Now(),
+	}
+
+	if wh.queue != nil {
+		if err := wh.queue.Submit(job); err != nil {
+			slog.Warn("重放消息入队失败, 将在下次启动时重试", "receiver", wh.receiver.Name, "group_key", entry.GroupKey, "id", entry.ID, "error", err)
+		}
 		return
 	}
+	if err := wh.deliver(job); err != nil {
+		wh.bury(job, err)
+	}
+}
+
+// Resend 重新发送一条死信，成功后删除该死信，失败时更新死信中的错误信息
+func (wh *WebhookHandler) Resend(deadLetter *outbox.DeadLetter) error {
+	providerConfig := wh.receiver.WebhookProvider
+	if deadLetter.Headers != nil {
+		providerConfig.Generic.Headers = deadLetter.Headers
+	}
+
+	if err := wh.messageHandler.SendMessage(providerConfig, deadLetter.Message); err != nil {
+		slog.Error("重新发送死信失败", "receiver", wh.receiver.Name, "group_key", deadLetter.GroupKey, "id", deadLetter.ID, "error", err)
+		deadLetter.Error = err.Error()
+		deadLetter.Response = delivery.ResponseOf(err)
+		deadLetter.FailedAt = time.Now()
+		deadLetter.Replays++
+		if updateErr := wh.outbox.UpdateDeadLetter(deadLetter); updateErr != nil {
+			slog.Error("更新死信失败", "receiver", wh.receiver.Name, "id", deadLetter.ID, "error", updateErr)
+		}
+		return err
+	}
+
+	slog.Info("重新发送死信成功", "receiver", wh.receiver.Name, "group_key", deadLetter.GroupKey, "id", deadLetter.ID)
+	return wh.outbox.DeleteDeadLetter(deadLetter.ID)
+}
+
+// deliver 发送一条已渲染的消息，成功后从发件箱中删除
+func (wh *WebhookHandler) deliver(job queue.Job) error {
+	err := wh.messageHandler.SendMessage(job.ProviderConfig, job.Message)
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	wh.stats.record(err == nil)
+	metrics.Deliveries.WithLabelValues(job.Receiver, result).Inc()
+	metrics.DeliveryLatency.WithLabelValues(job.Receiver, result).Observe(time.Since(job.EnqueuedAt).Seconds())
 
-	templateBaseName := filepath.Base(wh.providerConfig.Template)
-	templateName := strings.TrimSuffix(templateBaseName, filepath.Ext(templateBaseName)) + "_message"
+	if err != nil {
+		slog.Error("发送消息失败", "receiver", job.Receiver, "group_key", job.GroupKey, "error", err)
+		return err
+	}
+	wh.discard(job)
+	return nil
+}
 
-	if err := tmpl.ExecuteTemplate(&messageBuf, templateName, data); err != nil {
-		log.Printf("模板渲染失败: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "模板渲染失败"})
+// bury 将重试耗尽的消息移入死信，等待运维人员检查后通过 API 重新发送
+func (wh *WebhookHandler) bury(job queue.Job, err error) {
+	if wh.outbox == nil || job.ID == "" {
 		return
 	}
+	deadLetter := outbox.DeadLetter{
+		Entry: outbox.Entry{
+			ID:       job.ID,
+			Receiver: job.Receiver,
+			GroupKey: job.GroupKey,
+			Message:  job.Message,
+			Headers:  job.ProviderConfig.Generic.Headers,
+		},
+		Error:    err.Error(),
+		Response: delivery.ResponseOf(err),
+	}
+	if !job.EnqueuedAt.IsZero() {
+		deadLetter.CreatedAt = job.EnqueuedAt
+	}
+	if err := wh.outbox.Bury(&deadLetter); err != nil {
+		slog.Error("写入死信失败", "receiver", job.Receiver, "group_key", job.GroupKey, "id", job.ID, "error", err)
+		return
+	}
+	metrics.DeadLetters.WithLabelValues(job.Receiver).Inc()
+	slog.Warn("消息重试耗尽, 已移入死信", "receiver", job.Receiver, "group_key", job.GroupKey, "id", job.ID)
+}
 
-	// 发送消息
-	if err := wh.messageHandler.SendMessage(wh.providerConfig, messageBuf.String()); err != nil {
-		log.Printf("发送消息失败: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送消息失败"})
+// persist 在发送前将消息写入发件箱
+func (wh *WebhookHandler) persist(job *queue.Job) error {
+	if wh.outbox == nil {
+		return nil
+	}
+	entry := outbox.Entry{
+		Receiver: job.Receiver,
+		GroupKey: job.GroupKey,
+		Message:  job.Message,
+		Headers:  job.ProviderConfig.Generic.Headers,
+	}
+	if err := wh.outbox.Put(&entry); err != nil {
+		return err
+	}
+	job.ID = entry.ID
+	return nil
+}
+
+// discard 从发件箱中删除消息
+func (wh *WebhookHandler) discard(job queue.Job) {
+	if wh.outbox == nil || job.ID == "" {
 		return
 	}
+	if err := wh.outbox.Delete(job.ID); err != nil {
+		slog.Error("从发件箱删除消息失败", "receiver", job.Receiver, "id", job.ID, "error", err)
+	}
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "告警处理成功",
-		"sent_to": wh.providerConfig.WebhookURL,
-		"alerts":  len(webhookData.Alerts),
-	})
+// errorStatus 队列满或已关闭时返回 503，部分消息发送失败时返回 502，让 Alertmanager 稍后重试
+func errorStatus(err error) int {
+	if errors.Is(err, queue.ErrQueueFull) || errors.Is(err, queue.ErrQueueClosed) {
+		return http.StatusServiceUnavailable
+	}
+	var batchErr *delivery.BatchError
+	if errors.As(err, &batchErr) {
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
 }
 
-func (wh *WebhookHandler) prepareTemplateData(webhookData models.AlertmanagerWebhook) map[string]interface{} {
+// renderHeaders 渲染 generic 接收器中使用模板语法的请求头
+func (wh *WebhookHandler) renderHeaders(data map[string]interface{}) (map[string]string, error) {
+	headers := make(map[string]string, len(wh.receiver.Generic.Headers))
+	for key, value := range wh.receiver.Generic.Headers {
+		rendered, err := wh.templateService.RenderString(key, value, data)
+		if err != nil {
+			return nil, fmt.Errorf("请求头 %s: %w", key, err)
+		}
+		headers[key] = strings.TrimSpace(rendered)
+	}
+	return headers, nil
+}
+
+// bindAlertmanagerWebhook 读取并解析 Alertmanager 请求体，失败时直接写入错误响应
+func bindAlertmanagerWebhook(c *gin.Context) (models.AlertmanagerWebhook, bool) {
+	var webhookData models.AlertmanagerWebhook
+
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		slog.Warn("读取请求体失败", "route", c.FullPath(), "error", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无法读取请求"})
+		return webhookData, false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+	if slog.Default().Enabled(c.Request.Context(), slog.LevelDebug) {
+		slog.Debug("接收到原始告警 Webhook", "route", c.FullPath(), "size", len(bodyBytes),
+			"body", logging.Truncate(string(bodyBytes), maxLoggedBodySize))
+	}
+
+	if err := c.BindJSON(&webhookData); err != nil {
+		slog.Warn("解析告警 Webhook 失败", "route", c.FullPath(), "error", err)
+		metrics.WebhooksReceived.WithLabelValues(c.FullPath(), "invalid").Inc()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的JSON数据"})
+		return webhookData, false
+	}
+	metrics.WebhooksReceived.WithLabelValues(c.FullPath(), "ok").Inc()
+	return webhookData, true
+}
+
+// prepareTemplateData 准备模板数据，alertMentions 为每条告警需要 @ 的人，按 fingerprint 索引
+func (wh *WebhookHandler) prepareTemplateData(webhookData models.AlertmanagerWebhook, alertMentions map[string]models.Mentions) map[string]interface{} {
 	data := map[string]interface{}{
-		"alerts": webhookData.Alerts,
+		"status":            webhookData.Status,
+		"groupKey":          webhookData.GroupKey,
+		"groupLabels":       webhookData.GroupLabels,
+		"commonLabels":      webhookData.CommonLabels,
+		"commonAnnotations": webhookData.CommonAnnotations,
+		"externalURL":       webhookData.ExternalURL,
+		"alerts":            webhookData.Alerts,
 	}
 
 	var feishuAlerts []map[string]interface{}
 	for _, alert := range webhookData.Alerts {
-		feishuAlert := map[string]interface{}{
-			"Status":      alert.Status,
-			"Labels":      alert.Labels,
-			"Annotations": alert.Annotations,
-			"StartsAt":    alert.StartsAt,
-			"EndsAt":      alert.EndsAt,
-			"Fields":      getAlertFields(alert),
-		}
-		feishuAlerts = append(feishuAlerts, feishuAlert)
+		alertData := alertTemplateData(alert)
+		alertData["Mentions"] = alertMentions[alert.Fingerprint]
+		feishuAlerts = append(feishuAlerts, alertData)
 	}
 	data["alerts"] = feishuAlerts
 	return data
 }
 
+// alertTemplateData 单条告警在模板中可用的字段
+func alertTemplateData(alert models.Alert) map[string]interface{} {
+	return map[string]interface{}{
+		"Status":      alert.Status,
+		"Fingerprint": alert.Fingerprint,
+		"Labels":      alert.Labels,
+		"Annotations": alert.Annotations,
+		"StartsAt":    alert.StartsAt,
+		"EndsAt":      alert.EndsAt,
+		"Fields":      getAlertFields(alert),
+	}
+}
+
 // getAlertFields 提取告警中的标签用于飞书卡片展示
 func getAlertFields(alert models.Alert) []map[string]string {
 	var fields []map[string]string
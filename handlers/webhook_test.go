@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"prometheus-webhook/internal/delivery"
+	"prometheus-webhook/internal/metrics"
+	"prometheus-webhook/internal/outbox"
+	"prometheus-webhook/internal/queue"
+	"prometheus-webhook/models"
+	"prometheus-webhook/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testSender 记录发送的消息；block 不为空时在发送前等待它被关闭
+type testSender struct {
+	err     error
+	block   chan struct{}
+	started chan struct{}
+
+	mu       sync.Mutex
+	messages []string
+}
+
+func (s *testSender) SendMessage(providerConfig models.WebhookProvider, message string) error {
+	if s.started != nil {
+		s.started <- struct{}{}
+	}
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	s.messages = append(s.messages, strings.TrimSpace(message))
+	s.mu.Unlock()
+	return s.err
+}
+
+func (s *testSender) sent() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+// writeTemplate 在临时目录中写入模板文件 name.tmpl，text 为 name_message 子模板的内容
+func writeTemplate(t *testing.T, name, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name+".tmpl")
+	text = `{{ define "` + name + `_message" }}` + text + `{{ end }}`
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testReceiver 返回使用临时模板的接收器，模板把告警渲染为 "状态 告警名..."
+func testReceiver(t *testing.T) models.Receiver {
+	t.Helper()
+	return models.Receiver{
+		Name: "test",
+		Type: models.ReceiverTypeGeneric,
+		WebhookProvider: models.WebhookProvider{
+			Enable:     true,
+			WebhookURL: "http://127.0.0.1/hook",
+			Template:   writeTemplate(t, "test", `{{ .status }}{{ range .alerts }} {{ .Labels.alertname }}{{ end }}`),
+		},
+	}
+}
+
+func newTestHandler(t *testing.T, receiver models.Receiver, sender MessageHandler, store *outbox.Store) *WebhookHandler {
+	t.Helper()
+	wh, err := NewWebhookHandler(sender, receiver, services.NewTemplateService(time.UTC), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(wh.Close)
+	return wh
+}
+
+func postJSON(handler gin.HandlerFunc, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/webhook/test", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	handler(c)
+	return w
+}
+
+func testWebhook(status string, alertnames ...string) string {
+	var alerts []string
+	for _, name := range alertnames {
+		alerts = append(alerts, fmt.Sprintf(`{"status":%q,"labels":{"alertname":%q},"fingerprint":%q}`, status, name, name))
+	}
+	return fmt.Sprintf(`{"status":%q,"groupKey":"{}:{alertname=\"%s\"}","alerts":[%s]}`,
+		status, strings.Join(alertnames, ","), strings.Join(alerts, ","))
+}
+
+func TestHandleSync(t *testing.T) {
+	sender := &testSender{}
+	wh := newTestHandler(t, testReceiver(t), sender, nil)
+
+	if w := postJSON(wh.Handle, testWebhook("firing", "Disk")); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if got := sender.sent(); len(got) != 1 || got[0] != "firing Disk" {
+		t.Errorf("sent = %q", got)
+	}
+
+	sender.err = errors.New("boom")
+	if w := postJSON(wh.Handle, testWebhook("firing", "Disk")); w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500 when delivery fails", w.Code)
+	}
+	if w := postJSON(wh.Handle, `{"alerts":`); w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 for invalid JSON", w.Code)
+	}
+}
+
+func TestHandleMetrics(t *testing.T) {
+	wh := newTestHandler(t, testReceiver(t), &testSender{}, nil)
+	router := gin.New()
+	router.POST("/webhook/:name", wh.Handle)
+	post := func(body string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook/test", strings.NewReader(body)))
+	}
+
+	// 计数器是全局的，只比较本测试带来的变化
+	ok := metrics.WebhooksReceived.WithLabelValues("/webhook/:name", "ok")
+	invalid := metrics.WebhooksReceived.WithLabelValues("/webhook/:name", "invalid")
+	notifications := metrics.NotificationsReceived.WithLabelValues("test")
+	okBefore, invalidBefore, notificationsBefore := testutil.ToFloat64(ok), testutil.ToFloat64(invalid), testutil.ToFloat64(notifications)
+
+	post(testWebhook("firing", "Disk"))
+	post(`{"alerts":`)
+	if got := testutil.ToFloat64(ok) - okBefore; got != 1 {
+		t.Errorf("webhooks_received_total{result=ok} increased by %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(invalid) - invalidBefore; got != 1 {
+		t.Errorf("webhooks_received_total{result=invalid} increased by %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(notifications) - notificationsBefore; got != 1 {
+		t.Errorf("notifications_received_total{receiver=test} increased by %v, want 1", got)
+	}
+}
+
+func TestHandleQueueFull(t *testing.T) {
+	enable := true
+	receiver := testReceiver(t)
+	receiver.Queue = models.QueueConfig{Enable: &enable, Size: 1, Workers: 1, Overflow: models.QueueOverflowReject}
+	sender := &testSender{block: make(chan struct{}), started: make(chan struct{}, 4)}
+	wh := newTestHandler(t, receiver, sender, nil)
+	defer close(sender.block)
+
+	if w := postJSON(wh.Handle, testWebhook("firing", "A")); w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", w.Code)
+	}
+	<-sender.started // 工作协程取走第一条消息
+	if w := postJSON(wh.Handle, testWebhook("firing", "B")); w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", w.Code)
+	}
+	if w := postJSON(wh.Handle, testWebhook("firing", "C")); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503 when the queue is full", w.Code)
+	}
+}
+
+func openTestOutbox(t *testing.T) *outbox.Store {
+	t.Helper()
+	store, err := outbox.Open(filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func pendingCount(t *testing.T, store *outbox.Store) int {
+	t.Helper()
+	entries, err := store.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestProcessOutbox(t *testing.T) {
+	store := openTestOutbox(t)
+	sender := &testSender{}
+	wh := newTestHandler(t, testReceiver(t), sender, store)
+
+	if err := wh.Process(models.AlertmanagerWebhook{Status: "firing"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := pendingCount(t, store); n != 0 {
+		t.Errorf("pending = %d after a successful delivery, want 0", n)
+	}
+
+	// 同步发送失败时由 Alertmanager 重试，不保留在发件箱中，也不移入死信，避免重试成功后留下重复的死信
+	sender.err = errors.New("boom")
+	if w := postJSON(wh.Handle, testWebhook("firing", "Disk")); w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500 so that Alertmanager retries", w.Code)
+	}
+	if n := pendingCount(t, store); n != 0 {
+		t.Errorf("pending = %d after a failed sync delivery, want 0", n)
+	}
+	if deadLetters, err := store.DeadLetters(); err != nil || len(deadLetters) != 0 {
+		t.Errorf("deadletters = %+v, %v, want none for a failed sync delivery", deadLetters, err)
+	}
+}
+
+func TestAsyncFailureBuried(t *testing.T) {
+	store := openTestOutbox(t)
+	enable := true
+	receiver := testReceiver(t)
+	receiver.Queue = models.QueueConfig{Enable: &enable, Size: 1, Workers: 1, Overflow: models.QueueOverflowReject}
+
+	wh, err := NewWebhookHandler(&testSender{err: errors.New("boom")}, receiver, services.NewTemplateService(time.UTC), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wh.Process(models.AlertmanagerWebhook{Status: "firing"}); err != nil {
+		t.Fatal(err)
+	}
+	wh.Close()
+
+	if n := pendingCount(t, store); n != 0 {
+		t.Errorf("pending = %d, want the failed message moved out of the outbox", n)
+	}
+	deadLetters, err := store.DeadLetters()
+	if err != nil || len(deadLetters) != 1 {
+		t.Fatalf("deadletters = %+v, %v, want one", deadLetters, err)
+	}
+	if deadLetters[0].Receiver != "test" || deadLetters[0].Message != "firing" || deadLetters[0].Error != "boom" {
+		t.Errorf("deadletter = %+v", deadLetters[0])
+	}
+}
+
+func TestReplayDeletesDeliveredEntry(t *testing.T) {
+	store := openTestOutbox(t)
+	entry := outbox.Entry{Receiver: "test", Message: "firing"}
+	if err := store.Put(&entry); err != nil {
+		t.Fatal(err)
+	}
+
+	sender := &testSender{}
+	wh := newTestHandler(t, testReceiver(t), sender, store)
+	wh.Replay(entry)
+	if got := sender.sent(); len(got) != 1 || got[0] != "firing" {
+		t.Errorf("replayed = %q, want the stored message", got)
+	}
+	if n := pendingCount(t, store); n != 0 {
+		t.Errorf("pending = %d after replay, want 0", n)
+	}
+}
+
+func TestProcessStormSummary(t *testing.T) {
+	receiver := testReceiver(t)
+	receiver.Storm = models.StormConfig{
+		Threshold: 2,
+		TopK:      1,
+		Template:  writeTemplate(t, "summary", `{{ .summary.total }} {{ range .summary.top }}{{ .Labels.alertname }}{{ end }} +{{ .summary.omitted }}`),
+	}
+	sender := &testSender{}
+	wh := newTestHandler(t, receiver, sender, nil)
+
+	// 未超过阈值时使用普通模板
+	if w := postJSON(wh.Handle, testWebhook("firing", "A", "B")); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if w := postJSON(wh.Handle, testWebhook("firing", "A", "B", "C")); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	want := []string{"firing A B", "3 A +2"}
+	if got := sender.sent(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent = %q, want %q", got, want)
+	}
+}
+
+func TestProcessDedup(t *testing.T) {
+	enable := true
+	receiver := testReceiver(t)
+	receiver.Dedup = models.DedupConfig{Enable: &enable, Window: time.Hour}
+	sender := &testSender{}
+	wh := newTestHandler(t, receiver, sender, nil)
+
+	// 发送失败时不记录状态，Alertmanager 重试时仍然会发送
+	sender.err = errors.New("boom")
+	if w := postJSON(wh.Handle, testWebhook("firing", "Disk")); w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500 when delivery fails", w.Code)
+	}
+	sender.err = nil
+	for i := 0; i < 2; i++ {
+		if w := postJSON(wh.Handle, testWebhook("firing", "Disk")); w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+		}
+	}
+	if w := postJSON(wh.Handle, testWebhook("resolved", "Disk")); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	want := []string{"firing Disk", "firing Disk", "resolved Disk"}
+	if got := sender.sent(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent = %q, want %q", got, want)
+	}
+}
+
+// mentionSender 记录合并前收到的提醒对象
+type mentionSender struct {
+	testSender
+	merged []models.Mentions
+}
+
+func (s *mentionSender) MergeMentions(message string, all models.Mentions, _ map[string]models.Mentions) (string, error) {
+	s.merged = append(s.merged, all)
+	return message, nil
+}
+
+func TestProcessMentions(t *testing.T) {
+	receiver := testReceiver(t)
+	receiver.Template = writeTemplate(t, "mentions", `{{ .status }}{{ range .alerts }} {{ .Labels.alertname }}{{ range .Mentions.UserIDs }}@{{ . }}{{ end }}{{ end }}`)
+	receiver.Mentions = models.MentionConfig{
+		Rules: []models.MentionRule{{Matchers: []string{`alertname="Disk"`}, UserIDs: []string{"ou_dba"}}},
+	}
+	sender := &mentionSender{}
+	wh := newTestHandler(t, receiver, sender, nil)
+
+	for _, status := range []string{"firing", "resolved"} {
+		if w := postJSON(wh.Handle, testWebhook(status, "Disk", "CPU")); w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+		}
+	}
+	want := []string{"firing Disk@ou_dba CPU", "resolved Disk CPU"}
+	if got := sender.sent(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent = %q, want %q", got, want)
+	}
+	// 已恢复的告警不需要 @ 任何人，不调用 MergeMentions
+	if len(sender.merged) != 1 || !reflect.DeepEqual(sender.merged[0].UserIDs, []string{"ou_dba"}) {
+		t.Errorf("merged = %+v, want only the firing notification", sender.merged)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("消息入队失败: %w", queue.ErrQueueFull), http.StatusServiceUnavailable},
+		{fmt.Errorf("消息入队失败: %w", queue.ErrQueueClosed), http.StatusServiceUnavailable},
+		{fmt.Errorf("发送消息失败: %w", &delivery.BatchError{Name: "飞书消息", Err: errors.New("boom")}), http.StatusBadGateway},
+		{errors.New("发送消息失败"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := errorStatus(tt.err); got != tt.want {
+			t.Errorf("errorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+// TestBundledTemplatesEscapeValues 多行描述和引号等内容必须被转义，渲染结果仍然是合法的 JSON
+func TestBundledTemplatesEscapeValues(t *testing.T) {
+	description := "磁盘使用率超过 90%\n请检查 \"/data\" 目录"
+	webhook := models.AlertmanagerWebhook{
+		Status: "resolved",
+		Alerts: []models.Alert{{
+			Status:      "resolved",
+			Labels:      map[string]string{"alertname": `Disk "full"`, "severity": "critical", "namespace": "ops\\prod"},
+			Annotations: map[string]string{"summary": "磁盘\t空间不足", "description": description},
+		}},
+	}
+	tests := []struct {
+		name         string
+		receiverType string
+		template     string
+	}{
+		{"slack", models.ReceiverTypeSlack, "slack.tmpl"},
+		{"teams", models.ReceiverTypeTeams, "teams.tmpl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := testReceiver(t)
+			receiver.Type = tt.receiverType
+			receiver.Template = filepath.Join("..", "templates", tt.template)
+			sender := &testSender{}
+			if err := newTestHandler(t, receiver, sender, nil).Process(webhook); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			sent := sender.sent()
+			if len(sent) != 1 {
+				t.Fatalf("sent = %d messages, want 1", len(sent))
+			}
+			var message interface{}
+			if err := json.Unmarshal([]byte(sent[0]), &message); err != nil {
+				t.Fatalf("rendered message is not valid JSON: %v\n%s", err, sent[0])
+			}
+			// 转义后的描述能从 JSON 中原样还原
+			encoded, _ := json.Marshal(description)
+			if !strings.Contains(sent[0], strings.Trim(string(encoded), `"`)) {
+				t.Errorf("message does not contain the escaped description:\n%s", sent[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"prometheus-webhook/internal/logging"
+	"prometheus-webhook/models"
+)
+
+// maxErrorBodySize 错误信息中保留的响应内容的最大字节数
+const maxErrorBodySize = 512
+
+// Client 调用 Alertmanager v2 API
+type Client struct {
+	httpClient *http.Client
+}
+
+// NewClient 创建 Alertmanager 客户端，timeout 为单次请求的超时时间
+func NewClient(timeout time.Duration) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// CreateSilence 创建静默，返回静默 ID
+func (c *Client) CreateSilence(ctx context.Context, baseURL string, silence models.Silence) (string, error) {
+	u, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("Alertmanager 地址无效")
+	}
+
+	jsonData, err := json.Marshal(silence)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String()+"/api/v2/silences", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// 错误信息中的 URL 可能包含认证信息，只保留底层错误
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return "", fmt.Errorf("请求 Alertmanager 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("创建静默失败, 状态码: %d, 响应: %s", resp.StatusCode,
+			logging.Truncate(strings.TrimSpace(string(body)), maxErrorBodySize))
+	}
+
+	var result struct {
+		SilenceID string `json:"silenceID"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("解析响应失败: %w", err)
+	}
+	return result.SilenceID, nil
+}
+
+// Matchers 返回与标签完全相等的匹配条件，按标签名排序
+func Matchers(labels map[string]string) []models.SilenceMatcher {
+	matchers := make([]models.SilenceMatcher, 0, len(labels))
+	for name, value := range labels {
+		matchers = append(matchers, models.SilenceMatcher{Name: name, Value: value, IsEqual: true})
+	}
+	sort.Slice(matchers, func(i, j int) bool {
+		return matchers[i].Name < matchers[j].Name
+	})
+	return matchers
+}
@@ -0,0 +1,78 @@
+package dedup
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval 清理过期记录的最短间隔
+const sweepInterval = time.Minute
+
+// Cache 记录每条告警最后一次发送的状态和时间，用于过滤 Alertmanager 的重复通知
+// 状态变化 (新触发、恢复) 总是会被发送，相同状态在 window 内不会重复发送；
+// 设置了 reminder 时，持续触发的告警每隔 reminder 再发送一次提醒
+type Cache struct {
+	mu        sync.Mutex
+	window    time.Duration
+	reminder  time.Duration
+	entries   map[string]entry
+	lastSweep time.Time
+}
+
+type entry struct {
+	status string
+	sentAt time.Time
+}
+
+func New(window, reminder time.Duration) *Cache {
+	return &Cache{
+		window:    window,
+		reminder:  reminder,
+		entries:   make(map[string]entry),
+		lastSweep: time.Now(),
+	}
+}
+
+// Check 判断告警是否需要发送，reminder 为 true 表示这是一条持续触发的提醒
+func (c *Cache) Check(key, status string) (forward bool, reminder bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.sweep(now)
+
+	last, ok := c.entries[key]
+	if !ok || last.status != status {
+		return true, false
+	}
+	elapsed := now.Sub(last.sentAt)
+	if status == "firing" && c.reminder > 0 && elapsed >= c.reminder {
+		return true, true
+	}
+	if elapsed >= c.window {
+		return true, false
+	}
+	return false, false
+}
+
+// Mark 记录告警已经发送成功或已经入队
+func (c *Cache) Mark(key, status string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry{status: status, sentAt: time.Now()}
+}
+
+// sweep 删除已经超过去重窗口和提醒间隔的记录，调用方需持有锁
+func (c *Cache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < sweepInterval {
+		return
+	}
+	c.lastSweep = now
+
+	ttl := max(c.window, c.reminder)
+	for key, e := range c.entries {
+		if now.Sub(e.sentAt) >= ttl {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package dedup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	c := New(time.Hour, 0)
+
+	if forward, _ := c.Check("g|fp1", "firing"); !forward {
+		t.Fatal("Check() forward = false for a new alert")
+	}
+	c.Mark("g|fp1", "firing")
+
+	if forward, _ := c.Check("g|fp1", "firing"); forward {
+		t.Error("Check() forward = true for a repeated notification within the window")
+	}
+	if forward, _ := c.Check("g|fp1", "resolved"); !forward {
+		t.Error("Check() forward = false for a status change")
+	}
+	if forward, _ := c.Check("g|fp2", "firing"); !forward {
+		t.Error("Check() forward = false for another alert in the same group")
+	}
+}
+
+func TestCheckUnmarked(t *testing.T) {
+	c := New(time.Hour, 0)
+	// 发送失败的告警没有被记录，Alertmanager 重试时需要再次发送
+	c.Check("g|fp1", "firing")
+	if forward, _ := c.Check("g|fp1", "firing"); !forward {
+		t.Error("Check() forward = false for an alert that was never marked as sent")
+	}
+}
+
+func TestCheckWindowExpired(t *testing.T) {
+	c := New(20*time.Millisecond, 0)
+	c.Mark("g|fp1", "firing")
+	time.Sleep(30 * time.Millisecond)
+	forward, reminder := c.Check("g|fp1", "firing")
+	if !forward || reminder {
+		t.Errorf("Check() = (%v, %v) after the window, want (true, false)", forward, reminder)
+	}
+}
+
+func TestCheckReminder(t *testing.T) {
+	c := New(time.Hour, 20*time.Millisecond)
+	c.Mark("g|fp1", "firing")
+	c.Mark("g|fp2", "resolved")
+
+	if forward, _ := c.Check("g|fp1", "firing"); forward {
+		t.Error("Check() forward = true before the reminder interval")
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	forward, reminder := c.Check("g|fp1", "firing")
+	if !forward || !reminder {
+		t.Errorf("Check() = (%v, %v) after the reminder interval, want (true, true)", forward, reminder)
+	}
+	// 已恢复的告警不发送提醒
+	if forward, _ := c.Check("g|fp2", "resolved"); forward {
+		t.Error("Check() forward = true for a resolved alert within the window")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	c := New(10*time.Millisecond, 0)
+	c.Mark("g|fp1", "firing")
+	time.Sleep(20 * time.Millisecond)
+
+	c.mu.Lock()
+	c.sweep(time.Now().Add(sweepInterval))
+	remaining := len(c.entries)
+	c.mu.Unlock()
+	if remaining != 0 {
+		t.Errorf("entries = %d after sweep, want 0", remaining)
+	}
+}
@@ -0,0 +1,111 @@
+package delivery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MessageResult 一条通知拆分出的多条消息中，单条消息的发送结果
+type MessageResult struct {
+	Index    int    `json:"index"` // 从 1 开始的序号
+	Success  bool   `json:"success"`
+	Skipped  bool   `json:"skipped,omitempty"` // 上次发送已成功，本次没有重复发送
+	Attempts int    `json:"attempts,omitempty"`
+	Code     string `json:"code,omitempty"` // 平台错误码或 HTTP 状态码
+	Error    string `json:"error,omitempty"`
+}
+
+// BatchError 多条消息中有消息发送失败，Results 包含每条消息的结果
+type BatchError struct {
+	Name    string
+	Results []MessageResult
+	// Err 第一条失败消息的错误，携带最后一次响应，便于写入死信
+	Err error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d 条%s中 %d 条发送失败: %v", len(e.Results), e.Name, e.Failed(), e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Failed 返回发送失败的消息数
+func (e *BatchError) Failed() int {
+	failed := 0
+	for _, result := range e.Results {
+		if !result.Success {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Progress 记录部分发送失败的通知中已经发送成功的消息，
+// Alertmanager 或死信重新发送同一通知时跳过这些消息，避免群里出现重复的卡片
+type Progress struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	batches map[string]batchProgress
+}
+
+type batchProgress struct {
+	delivered map[int]bool
+	updatedAt time.Time
+}
+
+// NewProgress 创建发送进度记录，超过 ttl 没有重试的记录会被清理
+func NewProgress(ttl time.Duration) *Progress {
+	return &Progress{
+		ttl:     ttl,
+		batches: make(map[string]batchProgress),
+	}
+}
+
+// Delivered 返回上次发送同一通知时已经成功的消息序号
+func (p *Progress) Delivered(receiver, message string) map[int]bool {
+	key := progressKey(receiver, message)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	batch, ok := p.batches[key]
+	if !ok || time.Since(batch.updatedAt) > p.ttl {
+		return nil
+	}
+	return batch.delivered
+}
+
+// Record 记录本次发送的结果，全部成功时删除记录
+func (p *Progress) Record(receiver, message string, results []MessageResult) {
+	key := progressKey(receiver, message)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for k, batch := range p.batches {
+		if now.Sub(batch.updatedAt) > p.ttl {
+			delete(p.batches, k)
+		}
+	}
+
+	delivered := make(map[int]bool, len(results))
+	for _, result := range results {
+		if result.Success {
+			delivered[result.Index] = true
+		}
+	}
+	if len(delivered) == len(results) {
+		delete(p.batches, key)
+		return
+	}
+	p.batches[key] = batchProgress{delivered: delivered, updatedAt: now}
+}
+
+// progressKey 使用接收器和完整的通知内容标识一次发送
+func progressKey(receiver, message string) string {
+	sum := sha256.Sum256([]byte(receiver + "\x00" + message))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,75 @@
+package delivery
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatchError(t *testing.T) {
+	cause := &Error{Attempts: 3, StatusCode: 200, Err: &ProviderError{Provider: "飞书", Code: 11232, Message: "frequency limited"}}
+	err := &BatchError{
+		Name: "飞书消息",
+		Results: []MessageResult{
+			{Index: 1, Success: true},
+			{Index: 2, Success: false, Code: "11232"},
+			{Index: 3, Success: true, Skipped: true},
+		},
+		Err: cause,
+	}
+	if err.Failed() != 1 {
+		t.Errorf("Failed() = %d, want 1", err.Failed())
+	}
+	if !strings.HasPrefix(err.Error(), "3 条飞书消息中 1 条发送失败") {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	if CodeOf(err) != "11232" {
+		t.Errorf("CodeOf() = %q, want the code of the first failure", CodeOf(err))
+	}
+	var deliveryErr *Error
+	if !errors.As(err, &deliveryErr) || deliveryErr != cause {
+		t.Error("BatchError must unwrap to the first failure")
+	}
+}
+
+func TestProgressSkipsDeliveredMessagesOnRetry(t *testing.T) {
+	p := NewProgress(time.Hour)
+	if delivered := p.Delivered("feishu", "msg"); len(delivered) != 0 {
+		t.Fatalf("Delivered() = %v before any attempt, want none", delivered)
+	}
+
+	p.Record("feishu", "msg", []MessageResult{
+		{Index: 1, Success: true},
+		{Index: 2, Success: false},
+		{Index: 3, Success: true},
+	})
+	delivered := p.Delivered("feishu", "msg")
+	if !delivered[1] || delivered[2] || !delivered[3] {
+		t.Errorf("Delivered() = %v, want cards 1 and 3", delivered)
+	}
+
+	// 不同接收器或不同内容的通知互不影响
+	if len(p.Delivered("feishu-dba", "msg")) != 0 || len(p.Delivered("feishu", "other")) != 0 {
+		t.Error("Delivered() must be keyed by receiver and message")
+	}
+
+	// 重试时全部成功后删除记录
+	p.Record("feishu", "msg", []MessageResult{
+		{Index: 1, Success: true, Skipped: true},
+		{Index: 2, Success: true},
+		{Index: 3, Success: true, Skipped: true},
+	})
+	if delivered := p.Delivered("feishu", "msg"); len(delivered) != 0 {
+		t.Errorf("Delivered() = %v after all succeeded, want none", delivered)
+	}
+}
+
+func TestProgressExpires(t *testing.T) {
+	p := NewProgress(10 * time.Millisecond)
+	p.Record("feishu", "msg", []MessageResult{{Index: 1, Success: true}, {Index: 2}})
+	time.Sleep(20 * time.Millisecond)
+	if delivered := p.Delivered("feishu", "msg"); len(delivered) != 0 {
+		t.Errorf("Delivered() = %v after ttl, want none", delivered)
+	}
+}
@@ -0,0 +1,60 @@
+package delivery
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrRateLimited 接收器的限流器需要等待的时间超过了允许的范围，消息没有发送
+var ErrRateLimited = errors.New("接收器限流等待时间过长")
+
+// Error 发送失败的详细信息，携带最后一次尝试的响应内容，便于写入死信
+type Error struct {
+	Attempts   int
+	StatusCode int
+	Response   string
+	Err        error
+}
+
+// Error 的文本只包含尝试次数，最后一次的状态码和响应内容已经包含在 Err 中
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v (尝试 %d 次)", e.Err, e.Attempts)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ResponseOf 返回错误中携带的最后一次响应内容，没有时返回空字符串
+func ResponseOf(err error) string {
+	var deliveryErr *Error
+	if errors.As(err, &deliveryErr) {
+		return deliveryErr.Response
+	}
+	return ""
+}
+
+// CodeOf 返回错误中的平台错误码，没有时返回最后一次响应的 HTTP 状态码，都没有时返回空字符串
+func CodeOf(err error) string {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return strconv.Itoa(providerErr.Code)
+	}
+	var deliveryErr *Error
+	if errors.As(err, &deliveryErr) && deliveryErr.StatusCode != 0 {
+		return strconv.Itoa(deliveryErr.StatusCode)
+	}
+	return ""
+}
+
+// ProviderError 平台在响应中返回的业务错误码，例如钉钉的 errcode、飞书的 code
+type ProviderError struct {
+	Provider string
+	Code     int
+	Message  string
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s API返回错误 %d: %s", e.Provider, e.Code, e.Message)
+}
@@ -0,0 +1,124 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"prometheus-webhook/internal/logging"
+	"prometheus-webhook/models"
+)
+
+// maxErrorBodySize 错误信息中保留的响应内容的最大字节数
+const maxErrorBodySize = 512
+
+// Request 描述一次 HTTP 发送，提供者只需要实现构造请求和判断响应两个钩子
+type Request struct {
+	// Name 用于日志和错误信息，例如 "钉钉消息"
+	Name string
+	// Build 在每次尝试时调用，签名等与时间相关的参数应在这里计算
+	Build func(ctx context.Context) (*http.Request, error)
+	// Classify 判断响应是否成功，返回 nil 表示成功；
+	// 使用 Permanent 包装的错误不再重试，使用 RetryAfter 包装的错误按指定时间等待
+	Classify func(resp *http.Response, body []byte) error
+}
+
+// Client 按重试策略发送消息，每个接收器一个，同一接收器的所有发送共享一个限流器
+type Client struct {
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	limiter       *Limiter
+	limiterConfig rateLimitKey
+}
+
+type rateLimitKey struct {
+	enable   bool
+	limit    int
+	interval time.Duration
+	burst    int
+}
+
+func NewClient() *Client {
+	return &Client{
+		httpClient: &http.Client{},
+	}
+}
+
+// Send 按策略发送请求，失败时返回携带最后一次响应的 *Error
+func (c *Client) Send(policy Policy, request Request) error {
+	_, err := c.SendWithAttempts(policy, request)
+	return err
+}
+
+// SendWithAttempts 与 Send 相同，同时返回实际尝试的次数，用于报告多条消息各自的发送结果
+func (c *Client) SendWithAttempts(policy Policy, request Request) (int, error) {
+	return c.retry(policy, request.Name, func(ctx context.Context) (*Response, error) {
+		req, err := request.Build(ctx)
+		if err != nil {
+			return nil, Permanent(fmt.Errorf("创建请求失败: %w", err))
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			// 错误信息中的 URL 可能包含 access_token、bot token 等凭据，只保留底层错误
+			if urlErr, ok := err.(*url.Error); ok {
+				err = fmt.Errorf("%s 请求失败: %w", urlErr.Op, urlErr.Err)
+			}
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return &Response{StatusCode: resp.StatusCode}, fmt.Errorf("读取响应失败: %w", err)
+		}
+		return &Response{StatusCode: resp.StatusCode, Body: string(body)}, request.Classify(resp, body)
+	})
+}
+
+// limiterFor 返回与限流配置对应的限流器，配置变化时重新创建
+func (c *Client) limiterFor(cfg models.RateLimitConfig) *Limiter {
+	key := rateLimitKey{enable: cfg.Enabled(), limit: cfg.Limit, interval: cfg.Interval, burst: cfg.Burst}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.limiter == nil || c.limiterConfig != key {
+		if key.enable {
+			c.limiter = NewLimiter(key.limit, key.interval, key.burst)
+		} else {
+			c.limiter = NewLimiter(0, 0, 0)
+		}
+		c.limiterConfig = key
+	}
+	return c.limiter
+}
+
+// StatusError 返回非成功状态码对应的错误，429 会带上 Retry-After 指定的等待时间
+func StatusError(resp *http.Response, body []byte) error {
+	// 网关错误页等响应可能很大，错误信息中只保留开头部分，完整内容见 debug 日志
+	err := fmt.Errorf("状态码: %d, 响应: %s", resp.StatusCode, logging.Truncate(strings.TrimSpace(string(body)), maxErrorBodySize))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return RetryAfter(err, ParseRetryAfter(resp.Header.Get("Retry-After")))
+	case resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= http.StatusInternalServerError:
+		return err
+	default:
+		return Permanent(err)
+	}
+}
+
+// ParseRetryAfter 解析以秒为单位的 Retry-After 响应头，无法解析时返回 0
+func ParseRetryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,83 @@
+package delivery
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter 令牌桶限流器，每个接收器一个，同一接收器的所有发送协程共享
+// 平台返回限流错误时可以暂停整个接收器的发送
+type Limiter struct {
+	mu          sync.Mutex
+	interval    time.Duration // 生成一个令牌的间隔，为 0 表示不限流
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// NewLimiter 创建每 per 时间内最多 limit 条、允许突发 burst 条的限流器，limit 为 0 时只支持暂停
+func NewLimiter(limit int, per time.Duration, burst int) *Limiter {
+	l := &Limiter{last: time.Now()}
+	if limit > 0 && per > 0 {
+		if burst <= 0 {
+			burst = 1
+		}
+		l.interval = per / time.Duration(limit)
+		l.burst = float64(burst)
+		l.tokens = float64(burst)
+	}
+	return l
+}
+
+// Wait 阻塞直到可以发送下一条消息，返回等待的时间
+// 需要等待的时间超过 maxWait 时不预占令牌也不等待，返回需要等待的时间和 false
+func (l *Limiter) Wait(maxWait time.Duration) (time.Duration, bool) {
+	wait, ok := l.reserve(maxWait)
+	if ok && wait > 0 {
+		time.Sleep(wait)
+	}
+	return wait, ok
+}
+
+// reserve 预占一个令牌并返回需要等待的时间，令牌不足时允许为负，由后来者排队等待；
+// 等待时间超过 maxWait 的请求不预占令牌，因此令牌最多欠下 maxWait 内生成的数量
+func (l *Limiter) reserve(maxWait time.Duration) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	if l.pausedUntil.After(now) {
+		wait = l.pausedUntil.Sub(now)
+	}
+	if l.interval == 0 {
+		return wait, wait <= maxWait
+	}
+
+	l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		wait = max(wait, time.Duration((1-l.tokens)*float64(l.interval)))
+	}
+	if wait > maxWait {
+		return wait, false
+	}
+	l.tokens--
+	return wait, true
+}
+
+// Pause 在 d 时间内暂停发送，用于平台返回限流错误之后
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
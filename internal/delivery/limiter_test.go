@@ -0,0 +1,74 @@
+package delivery
+
+import (
+	"testing"
+	"time"
+
+	"prometheus-webhook/models"
+)
+
+func TestLimiterBurst(t *testing.T) {
+	l := NewLimiter(10, time.Second, 3)
+	for i := 0; i < 3; i++ {
+		if wait, _ := l.reserve(time.Minute); wait > 0 {
+			t.Fatalf("reserve() #%d wait = %s, want 0 within burst", i+1, wait)
+		}
+	}
+	// 令牌用完后每 100ms 生成一个令牌，排队的请求依次等待
+	if wait, _ := l.reserve(time.Minute); wait < 50*time.Millisecond || wait > 100*time.Millisecond {
+		t.Errorf("reserve() #4 wait = %s, want about 100ms", wait)
+	}
+	if wait, _ := l.reserve(time.Minute); wait < 150*time.Millisecond || wait > 200*time.Millisecond {
+		t.Errorf("reserve() #5 wait = %s, want about 200ms", wait)
+	}
+}
+
+func TestLimiterMaxWait(t *testing.T) {
+	l := NewLimiter(10, time.Second, 1)
+	l.reserve(time.Minute)
+	if wait, ok := l.reserve(50 * time.Millisecond); ok || wait < 50*time.Millisecond {
+		t.Fatalf("reserve() = %s, %v, want the wait to exceed maxWait", wait, ok)
+	}
+	// 超过 maxWait 的请求没有预占令牌，不会让后面的请求等待更久
+	if wait, ok := l.reserve(time.Minute); !ok || wait > 100*time.Millisecond {
+		t.Errorf("reserve() = %s, %v, want about 100ms", wait, ok)
+	}
+}
+
+func TestLimiterRefill(t *testing.T) {
+	l := NewLimiter(100, time.Second, 1)
+	l.reserve(time.Minute)
+	time.Sleep(15 * time.Millisecond)
+	if wait, _ := l.reserve(time.Minute); wait > 0 {
+		t.Errorf("reserve() wait = %s after refill, want 0", wait)
+	}
+}
+
+func TestLimiterPause(t *testing.T) {
+	l := NewLimiter(0, 0, 0)
+	if wait, _ := l.reserve(time.Minute); wait != 0 {
+		t.Fatalf("reserve() wait = %s, want 0 without limit", wait)
+	}
+
+	l.Pause(200 * time.Millisecond)
+	// 更短的暂停不会缩短已有的暂停
+	l.Pause(10 * time.Millisecond)
+	if wait, _ := l.reserve(time.Minute); wait < 150*time.Millisecond || wait > 200*time.Millisecond {
+		t.Errorf("reserve() wait = %s while paused, want about 200ms", wait)
+	}
+}
+
+func TestClientLimiterFor(t *testing.T) {
+	enable := true
+	c := NewClient()
+	cfg := models.RateLimitConfig{Enable: &enable, Limit: 20, Interval: time.Minute, Burst: 5}
+
+	first := c.limiterFor(cfg)
+	if c.limiterFor(cfg) != first {
+		t.Error("limiterFor() returned a new limiter for the same config")
+	}
+	cfg.Limit = 10
+	if c.limiterFor(cfg) == first {
+		t.Error("limiterFor() kept the old limiter after the config changed")
+	}
+}
@@ -0,0 +1,216 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"prometheus-webhook/internal/logging"
+	"prometheus-webhook/internal/metrics"
+	"prometheus-webhook/models"
+)
+
+// maxLoggedResponseSize debug 日志中记录的响应内容的最大字节数
+const maxLoggedResponseSize = 4 << 10
+
+// MaxRetryAfter 服务端要求的等待时间超过该值时不再重试，直接返回错误，
+// 由 Alertmanager 或死信稍后重新发送，避免一条消息阻塞发送协程过久
+const MaxRetryAfter = 60 * time.Second
+
+// Policy 一次发送的重试策略
+type Policy struct {
+	Receiver        string // 用于指标
+	MaxAttempts     int
+	Timeout         time.Duration // 单次尝试的超时时间
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	Jitter          float64
+	MaxElapsedTime  time.Duration
+	RateLimit       models.RateLimitConfig
+}
+
+// NewPolicy 根据接收器配置创建重试策略
+func NewPolicy(providerConfig models.WebhookProvider) Policy {
+	return Policy{
+		Receiver:        providerConfig.ReceiverName,
+		MaxAttempts:     providerConfig.RetryCount,
+		Timeout:         providerConfig.Timeout,
+		InitialInterval: providerConfig.Backoff.InitialInterval,
+		MaxInterval:     providerConfig.Backoff.MaxInterval,
+		Multiplier:      providerConfig.Backoff.Multiplier,
+		Jitter:          providerConfig.Backoff.Jitter,
+		MaxElapsedTime:  providerConfig.Backoff.MaxElapsedTime,
+		RateLimit:       providerConfig.RateLimit,
+	}
+}
+
+// backoff 返回第 attempt 次尝试失败后的等待时间
+func (p Policy) backoff(attempt int) time.Duration {
+	interval := float64(p.InitialInterval) * math.Pow(p.Multiplier, float64(attempt-1))
+	if p.MaxInterval > 0 && interval > float64(p.MaxInterval) {
+		interval = float64(p.MaxInterval)
+	}
+	if p.Jitter > 0 {
+		delta := p.Jitter * interval
+		interval = interval - delta + rand.Float64()*2*delta
+	}
+	return time.Duration(interval)
+}
+
+// Response 一次尝试得到的响应，用于判断结果和写入死信
+type Response struct {
+	StatusCode int
+	Body       string
+}
+
+// Attempt 执行一次发送，ctx 带有单次尝试的超时时间
+type Attempt func(ctx context.Context) (*Response, error)
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记重试也不会成功的错误，例如签名错误、机器人不存在、消息格式错误
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// RetryAfter 标记可以重试的错误，并指定服务端要求的最短等待时间
+// 通常用于平台的限流错误，等待期间同一接收器的其他消息也会暂停发送
+func RetryAfter(err error, after time.Duration) error {
+	return &retryAfterError{err: err, after: after}
+}
+
+// Retry 按策略执行 attempt，直到成功、遇到永久性错误、次数用尽或超过最长重试时间
+// 每次尝试前先经过接收器的限流器，限流等待的时间计入最长重试时间，
+// 需要等待的时间超过剩余的重试时间或 MaxRetryAfter 时返回 ErrRateLimited，由 Alertmanager 或死信稍后重新发送
+// name 用于日志和错误信息，例如 "钉钉消息"
+func (c *Client) Retry(policy Policy, name string, attempt Attempt) error {
+	_, err := c.retry(policy, name, attempt)
+	return err
+}
+
+// retry 与 Retry 相同，同时返回实际尝试的次数
+func (c *Client) retry(policy Policy, name string, attempt Attempt) (int, error) {
+	limiter := c.limiterFor(policy.RateLimit)
+	start := time.Now()
+	var lastResp *Response
+	for i := 1; ; i++ {
+		maxWait := MaxRetryAfter
+		if policy.MaxElapsedTime > 0 {
+			maxWait = min(maxWait, policy.MaxElapsedTime-time.Since(start))
+		}
+		wait, ok := limiter.Wait(maxWait)
+		if !ok {
+			slog.Warn(name+"因限流需要等待的时间过长, 放弃发送", "receiver", policy.Receiver, "attempt", i, "wait", wait.Round(time.Millisecond))
+			err := RetryAfter(fmt.Errorf("发送%s失败，需要等待 %s: %w", name, wait.Round(time.Millisecond), ErrRateLimited), wait)
+			return i - 1, newError(i-1, lastResp, err)
+		}
+		metrics.RateLimitWait.WithLabelValues(policy.Receiver).Observe(wait.Seconds())
+		if wait > 0 {
+			slog.Debug(name+"因限流等待", "receiver", policy.Receiver, "attempt", i, "wait", wait.Round(time.Millisecond))
+		}
+		if i > 1 {
+			metrics.SendRetries.WithLabelValues(policy.Receiver).Inc()
+		}
+
+		attemptStart := time.Now()
+		resp, err := runAttempt(policy.Timeout, attempt)
+		metrics.SendDuration.WithLabelValues(policy.Receiver).Observe(time.Since(attemptStart).Seconds())
+		if resp != nil {
+			lastResp = resp
+		}
+		if err == nil {
+			metrics.SendAttempts.WithLabelValues(policy.Receiver, "success", "").Inc()
+			return i, nil
+		}
+		metrics.SendAttempts.WithLabelValues(policy.Receiver, "failure", errorCode(resp, err)).Inc()
+		logAttempt(policy, name, i, resp, err)
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return i, newError(i, lastResp, fmt.Errorf("发送%s失败: %w", name, err))
+		}
+		if i >= policy.MaxAttempts {
+			return i, newError(i, lastResp, fmt.Errorf("发送%s失败，重试 %d 次后仍然失败: %w", name, i, err))
+		}
+
+		wait = policy.backoff(i)
+		var retryAfter *retryAfterError
+		if errors.As(err, &retryAfter) {
+			// 提前重试只会再次被限流，等待时间过长时放弃本次发送
+			if retryAfter.after > MaxRetryAfter {
+				return i, newError(i, lastResp, fmt.Errorf("发送%s失败，服务端要求等待 %s，超过最长等待时间 %s: %w", name, retryAfter.after, MaxRetryAfter, err))
+			}
+			if retryAfter.after > 0 {
+				limiter.Pause(retryAfter.after)
+			}
+			wait = max(wait, retryAfter.after)
+		}
+		if policy.MaxElapsedTime > 0 && time.Since(start)+wait > policy.MaxElapsedTime {
+			return i, newError(i, lastResp, fmt.Errorf("发送%s失败，超过最长重试时间 %s: %w", name, policy.MaxElapsedTime, err))
+		}
+		time.Sleep(wait)
+	}
+}
+
+// logAttempt 记录一次失败的尝试，响应内容只在 debug 级别记录并限制长度
+func logAttempt(policy Policy, name string, attempt int, resp *Response, err error) {
+	args := []any{"receiver", policy.Receiver, "attempt", attempt, "max_attempts", policy.MaxAttempts}
+	if resp != nil && resp.StatusCode != 0 {
+		args = append(args, "status_code", resp.StatusCode)
+	}
+	slog.Warn("发送"+name+"失败", append(args, "error", err)...)
+	if resp != nil && resp.Body != "" {
+		slog.Debug("发送"+name+"失败的响应内容", append(args, "response", logging.Truncate(resp.Body, maxLoggedResponseSize))...)
+	}
+}
+
+// runAttempt 在独立的超时上下文中执行一次尝试，尝试结束后立即释放上下文
+func runAttempt(timeout time.Duration, attempt Attempt) (*Response, error) {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return attempt(ctx)
+}
+
+// errorCode 返回用于指标的错误码: 平台错误码、HTTP 状态码，没有响应时为 network
+func errorCode(resp *Response, err error) string {
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) {
+		return strconv.Itoa(providerErr.Code)
+	}
+	if resp != nil && resp.StatusCode != 0 {
+		return strconv.Itoa(resp.StatusCode)
+	}
+	return "network"
+}
+
+func newError(attempts int, resp *Response, err error) *Error {
+	deliveryErr := &Error{Attempts: attempts, Err: err}
+	if resp != nil {
+		deliveryErr.StatusCode = resp.StatusCode
+		deliveryErr.Response = resp.Body
+	}
+	return deliveryErr
+}
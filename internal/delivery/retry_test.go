@@ -0,0 +1,337 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"prometheus-webhook/internal/provider/providertest"
+	"prometheus-webhook/models"
+)
+
+func testPolicy(maxAttempts int) Policy {
+	return Policy{
+		Receiver:        "delivery-test",
+		MaxAttempts:     maxAttempts,
+		Timeout:         time.Second,
+		InitialInterval: time.Millisecond,
+		Multiplier:      1,
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := Policy{InitialInterval: 100 * time.Millisecond, MaxInterval: time.Second, Multiplier: 2}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{10, time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	p := Policy{InitialInterval: time.Second, Multiplier: 1, Jitter: 0.2}
+	for i := 0; i < 100; i++ {
+		got := p.backoff(1)
+		if got < 800*time.Millisecond || got > 1200*time.Millisecond {
+			t.Fatalf("backoff(1) = %s, want within ±20%% of 1s", got)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	errTemporary := errors.New("temporary")
+	tests := []struct {
+		name         string
+		errs         []error
+		maxAttempts  int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{name: "第一次成功", errs: []error{nil}, maxAttempts: 3, wantAttempts: 1},
+		{name: "重试后成功", errs: []error{errTemporary, errTemporary, nil}, maxAttempts: 3, wantAttempts: 3},
+		{name: "次数用尽", errs: []error{errTemporary, errTemporary, errTemporary}, maxAttempts: 3, wantErr: true, wantAttempts: 3},
+		{name: "永久性错误不重试", errs: []error{Permanent(errTemporary)}, maxAttempts: 3, wantErr: true, wantAttempts: 1},
+		{name: "RetryAfter 可以重试", errs: []error{RetryAfter(errTemporary, 10*time.Millisecond), nil}, maxAttempts: 3, wantAttempts: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			attempts, err := NewClient().retry(testPolicy(tt.maxAttempts), "测试消息", func(ctx context.Context) (*Response, error) {
+				err := tt.errs[calls]
+				calls++
+				return &Response{StatusCode: 500, Body: "body"}, err
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("retry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts || calls != tt.wantAttempts {
+				t.Errorf("attempts = %d, calls = %d, want %d", attempts, calls, tt.wantAttempts)
+			}
+			if err != nil {
+				var deliveryErr *Error
+				if !errors.As(err, &deliveryErr) || deliveryErr.Attempts != tt.wantAttempts || deliveryErr.Response != "body" {
+					t.Errorf("error = %#v, want *Error with the last response", err)
+				}
+				if !errors.Is(err, errTemporary) {
+					t.Errorf("error = %v, want it to wrap the attempt error", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	calls := 0
+	start := time.Now()
+	err := NewClient().Retry(testPolicy(2), "测试消息", func(ctx context.Context) (*Response, error) {
+		calls++
+		if calls == 1 {
+			return nil, RetryAfter(errors.New("限流"), 200*time.Millisecond)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("Retry() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("retried after %s, want at least 200ms", elapsed)
+	}
+}
+
+func TestRetryGivesUpWhenRetryAfterTooLong(t *testing.T) {
+	calls := 0
+	start := time.Now()
+	err := NewClient().Retry(testPolicy(3), "测试消息", func(ctx context.Context) (*Response, error) {
+		calls++
+		return nil, RetryAfter(errors.New("限流"), MaxRetryAfter+time.Second)
+	})
+	if err == nil {
+		t.Fatal("Retry() error = nil, want error")
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Retry() took %s, want it to give up immediately", elapsed)
+	}
+}
+
+func TestRetryGivesUpWhenRateLimitWaitTooLong(t *testing.T) {
+	enable := true
+	policy := testPolicy(3)
+	policy.MaxElapsedTime = time.Second
+	policy.RateLimit = models.RateLimitConfig{Enable: &enable, Limit: 1, Interval: time.Hour, Burst: 1}
+	client := NewClient()
+
+	calls := 0
+	attempt := func(ctx context.Context) (*Response, error) {
+		calls++
+		return nil, nil
+	}
+	if err := client.Retry(policy, "测试消息", attempt); err != nil {
+		t.Fatalf("Retry() error = %v", err)
+	}
+	// 令牌用完后需要等待一小时，超过最长重试时间，不发送也不等待
+	start := time.Now()
+	err := client.Retry(policy, "测试消息", attempt)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Retry() error = %v, want ErrRateLimited", err)
+	}
+	var retryAfter *retryAfterError
+	if !errors.As(err, &retryAfter) || retryAfter.after < 59*time.Minute {
+		t.Errorf("Retry() error = %v, want a RetryAfter error with the limiter wait", err)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Retry() took %s, want it to give up immediately", elapsed)
+	}
+}
+
+func TestRetryMaxElapsedTime(t *testing.T) {
+	policy := testPolicy(100)
+	policy.InitialInterval = 50 * time.Millisecond
+	policy.MaxElapsedTime = 120 * time.Millisecond
+
+	calls := 0
+	err := NewClient().Retry(policy, "测试消息", func(ctx context.Context) (*Response, error) {
+		calls++
+		return nil, errors.New("temporary")
+	})
+	if err == nil || !strings.Contains(err.Error(), "超过最长重试时间") {
+		t.Fatalf("Retry() error = %v, want max elapsed time error", err)
+	}
+	if calls < 2 || calls > 4 {
+		t.Errorf("calls = %d, want 2 to 4 attempts within 120ms", calls)
+	}
+}
+
+func TestRetryAttemptTimeout(t *testing.T) {
+	policy := testPolicy(1)
+	policy.Timeout = 20 * time.Millisecond
+	err := NewClient().Retry(policy, "测试消息", func(ctx context.Context) (*Response, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Retry() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		status     int
+		retryAfter string
+		wantPerm   bool
+		wantAfter  time.Duration
+	}{
+		{status: http.StatusTooManyRequests, retryAfter: "7", wantAfter: 7 * time.Second},
+		{status: http.StatusTooManyRequests},
+		{status: http.StatusRequestTimeout},
+		{status: http.StatusInternalServerError},
+		{status: http.StatusBadGateway},
+		{status: http.StatusBadRequest, wantPerm: true},
+		{status: http.StatusUnauthorized, wantPerm: true},
+		{status: http.StatusNotFound, wantPerm: true},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		if tt.retryAfter != "" {
+			resp.Header.Set("Retry-After", tt.retryAfter)
+		}
+		err := StatusError(resp, []byte(" error body "))
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) != tt.wantPerm {
+			t.Errorf("StatusError(%d) permanent = %v, want %v", tt.status, !tt.wantPerm, tt.wantPerm)
+		}
+		var retryAfter *retryAfterError
+		if tt.status == http.StatusTooManyRequests {
+			if !errors.As(err, &retryAfter) || retryAfter.after != tt.wantAfter {
+				t.Errorf("StatusError(429) = %v, want RetryAfter %s", err, tt.wantAfter)
+			}
+		}
+		if !strings.Contains(err.Error(), "响应: error body") {
+			t.Errorf("StatusError(%d) = %q, want trimmed response body", tt.status, err)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":                              0,
+		"30":                            30 * time.Second,
+		" 5 ":                           5 * time.Second,
+		"-1":                            0,
+		"Wed, 21 Oct 2015 07:28:00 GMT": 0,
+	}
+	for value, want := range tests {
+		if got := ParseRetryAfter(value); got != want {
+			t.Errorf("ParseRetryAfter(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestCodeOf(t *testing.T) {
+	providerErr := &Error{Attempts: 1, StatusCode: 200, Err: &ProviderError{Provider: "钉钉", Code: 130101, Message: "send too fast"}}
+	if got := CodeOf(providerErr); got != "130101" {
+		t.Errorf("CodeOf(provider error) = %q, want 130101", got)
+	}
+	statusErr := &Error{Attempts: 1, StatusCode: 502, Response: "bad gateway", Err: errors.New("状态码: 502")}
+	if got := CodeOf(fmt.Errorf("发送失败: %w", statusErr)); got != "502" {
+		t.Errorf("CodeOf(status error) = %q, want 502", got)
+	}
+	if got := ResponseOf(statusErr); got != "bad gateway" {
+		t.Errorf("ResponseOf() = %q, want bad gateway", got)
+	}
+	if got := CodeOf(errors.New("network")); got != "" {
+		t.Errorf("CodeOf(plain error) = %q, want empty", got)
+	}
+	if got := ResponseOf(errors.New("network")); got != "" {
+		t.Errorf("ResponseOf(plain error) = %q, want empty", got)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *Response
+		err  error
+		want string
+	}{
+		{"平台错误码", &Response{StatusCode: http.StatusOK}, &ProviderError{Provider: "钉钉", Code: 310000}, "310000"},
+		{"包装后的平台错误码", nil, fmt.Errorf("发送失败: %w", &ProviderError{Code: 19001}), "19001"},
+		{"HTTP 状态码", &Response{StatusCode: http.StatusBadGateway}, errors.New("502"), "502"},
+		{"网络错误", nil, errors.New("connection refused"), "network"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorCode(tt.resp, tt.err); got != tt.want {
+				t.Errorf("errorCode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func checkStatus(resp *http.Response, body []byte) error {
+	if resp.StatusCode != http.StatusOK {
+		return StatusError(resp, body)
+	}
+	return nil
+}
+
+func TestSendWithAttempts(t *testing.T) {
+	server := providertest.NewServer(t,
+		providertest.Response{Status: http.StatusServiceUnavailable},
+		providertest.Response{Body: "ok"},
+	)
+
+	attempts, err := NewClient().SendWithAttempts(testPolicy(3), Request{
+		Name: "测试消息",
+		Build: func(ctx context.Context) (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, "POST", server.URL, nil)
+		},
+		Classify: checkStatus,
+	})
+	if err != nil {
+		t.Fatalf("SendWithAttempts() error = %v", err)
+	}
+	if n := len(server.Requests()); attempts != 2 || n != 2 {
+		t.Errorf("attempts = %d, requests = %d, want 2", attempts, n)
+	}
+}
+
+func TestSendKeepsLastResponse(t *testing.T) {
+	server := providertest.NewServer(t, providertest.Response{Status: http.StatusBadRequest, Body: `{"code":400}`})
+
+	err := NewClient().Send(testPolicy(3), Request{
+		Name: "测试消息",
+		Build: func(ctx context.Context) (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, "POST", server.URL, nil)
+		},
+		Classify: checkStatus,
+	})
+	var deliveryErr *Error
+	if !errors.As(err, &deliveryErr) {
+		t.Fatalf("Send() error = %v, want *Error", err)
+	}
+	if deliveryErr.Attempts != 1 || deliveryErr.StatusCode != http.StatusBadRequest || deliveryErr.Response != `{"code":400}` {
+		t.Errorf("error = %+v, want the last response of a single attempt", deliveryErr)
+	}
+}
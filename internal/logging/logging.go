@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"prometheus-webhook/internal/redact"
+)
+
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// KeyURL 记录 webhook 地址时使用的字段名，该字段的值在输出前会被脱敏
+const KeyURL = "url"
+
+var levels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// ParseLevel 解析 logging.level 配置，支持 debug, info, warn, error
+func ParseLevel(level string) (slog.Level, error) {
+	l, ok := levels[strings.ToLower(level)]
+	if !ok {
+		return 0, fmt.Errorf("不支持的日志级别: %s", level)
+	}
+	return l, nil
+}
+
+// New 创建写入 w 的结构化日志记录器，format 为 json 或 text
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: l, ReplaceAttr: redactAttr}
+	switch format {
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("不支持的日志格式: %s", format)
+	}
+}
+
+// redactAttr 对名为 url 的字段脱敏，避免 webhook 地址中的 access_token、key 等凭据出现在日志中
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == KeyURL && a.Value.Kind() == slog.KindString {
+		return slog.String(a.Key, redact.URL(a.Value.String()))
+	}
+	return a
+}
+
+// Setup 按配置创建输出到标准输出的日志记录器并设为默认记录器，
+// 标准库 log 的输出 (例如第三方库) 也会以 info 级别转到该记录器
+func Setup(level, format string) error {
+	logger, err := New(os.Stdout, level, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// Truncate 将 s 截断到最多 max 字节，用于记录请求体、响应体等可能很大的内容
+func Truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	// 避免截断在多字节字符中间
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max] + fmt.Sprintf("...(已截断, 共 %d 字节)", len(s))
+}
@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "WARN", FormatJSON)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	logger.Info("忽略")
+	logger.Warn("发送失败", "receiver", "ops")
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("output %q is not a single JSON line: %v", buf.String(), err)
+	}
+	if entry["msg"] != "发送失败" || entry["receiver"] != "ops" {
+		t.Errorf("entry = %v", entry)
+	}
+
+	if _, err := New(&buf, "trace", FormatText); err == nil {
+		t.Error("New() with unknown level succeeded")
+	}
+	if _, err := New(&buf, "info", "logfmt"); err == nil {
+		t.Error("New() with unknown format succeeded")
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := Truncate("short", 10); got != "short" {
+		t.Errorf("Truncate() = %q, want unchanged", got)
+	}
+
+	// "告警" 每个字占 3 字节，截断到 4 字节时只能保留第一个字
+	got := Truncate("告警内容", 4)
+	if !strings.HasPrefix(got, "告...") || !strings.Contains(got, "共 12 字节") {
+		t.Errorf("Truncate() = %q", got)
+	}
+}
@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "prometheus_webhook"
+
+var (
+	// BuildInfo 当前运行的版本，值恒为 1
+	BuildInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "build_info",
+		Help:      "Build information of the running binary, always 1.",
+	}, []string{"version", "commit", "go_version"})
+
+	// ConfigReloads 重新加载配置的次数，result 为 success 或 failure
+	ConfigReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_reloads_total",
+		Help:      "Configuration reload attempts, by result.",
+	}, []string{"result"})
+
+	// ConfigLastReloadSuccess 最近一次重新加载配置成功的时间
+	ConfigLastReloadSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "config_last_reload_success_timestamp_seconds",
+		Help:      "Timestamp of the last successful configuration load.",
+	})
+
+	// WebhooksReceived 每个接口收到的 Alertmanager 通知数，result 为 ok 或 invalid
+	WebhooksReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhooks_received_total",
+		Help:      "Alertmanager notifications received, by route and parse result.",
+	}, []string{"route", "result"})
+
+	// NotificationsReceived 每个接收器收到的通知数，/alert 的通知按路由拆分后分别计入各个接收器
+	NotificationsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_received_total",
+		Help:      "Alertmanager notifications dispatched to each receiver.",
+	}, []string{"receiver"})
+
+	// AlertsReceived 每个接收器收到的告警数
+	AlertsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_received_total",
+		Help:      "Alerts received by each receiver, by alert status.",
+	}, []string{"receiver", "status"})
+
+	// AlertsSuppressed 被去重过滤的告警数
+	AlertsSuppressed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "alerts_suppressed_total",
+		Help:      "Alerts dropped by deduplication because their state did not change.",
+	}, []string{"receiver"})
+
+	// StormSummaries 因告警风暴改为发送摘要的通知数
+	StormSummaries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storm_summaries_total",
+		Help:      "Notifications rendered with the storm summary template.",
+	}, []string{"receiver"})
+
+	// RenderFailures 模板加载或渲染失败的次数
+	RenderFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "template_render_failures_total",
+		Help:      "Template load or render failures.",
+	}, []string{"receiver"})
+
+	// TemplateReloads 模板文件变化后重新解析的次数，result 为 success 或 failure
+	TemplateReloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "template_reloads_total",
+		Help:      "Template file reloads after a change on disk, by result.",
+	}, []string{"template", "result"})
+
+	// SendAttempts 每次发送尝试的结果，code 为平台错误码、HTTP 状态码或 network
+	SendAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "send_attempts_total",
+		Help:      "Delivery attempts, by result and provider error code.",
+	}, []string{"receiver", "result", "code"})
+
+	// SendRetries 首次尝试之后的重试次数
+	SendRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "send_retries_total",
+		Help:      "Delivery attempts after the first one.",
+	}, []string{"receiver"})
+
+	// SendDuration 单次发送尝试的耗时
+	SendDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "send_attempt_duration_seconds",
+		Help:      "Duration of a single delivery attempt.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"receiver"})
+
+	// Deliveries 每条消息的最终发送结果，result 为 success 或 failure
+	Deliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deliveries_total",
+		Help:      "Messages delivered or given up after all retries.",
+	}, []string{"receiver", "result"})
+
+	// DeliveryLatency 消息从渲染完成到发送结束的耗时，包括排队、限流和重试
+	DeliveryLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "delivery_latency_seconds",
+		Help:      "Time from rendering a message until its delivery finished, including queueing, rate limiting and retries.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"receiver", "result"})
+
+	// RateLimitWait 发送前因限流等待的时间
+	RateLimitWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_wait_seconds",
+		Help:      "Time a delivery attempt waited for the receiver rate limiter.",
+		Buckets:   []float64{0.1, 0.5, 1, 3, 5, 10, 30, 60, 120},
+	}, []string{"receiver"})
+
+	// QueueLength 发送队列中等待的消息数
+	QueueLength = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_length",
+		Help:      "Messages waiting in the receiver delivery queue.",
+	}, []string{"receiver"})
+
+	// QueueCapacity 发送队列深度
+	QueueCapacity = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_capacity",
+		Help:      "Capacity of the receiver delivery queue.",
+	}, []string{"receiver"})
+
+	// QueueOverflows 队列满时被拒绝或丢弃的消息数，policy 为 reject 或 drop_oldest
+	QueueOverflows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "queue_overflows_total",
+		Help:      "Messages rejected or dropped because the delivery queue was full.",
+	}, []string{"receiver", "policy"})
+
+	// DeadLetters 移入死信的消息数
+	DeadLetters = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dead_letters_total",
+		Help:      "Messages moved to the dead-letter store.",
+	}, []string{"receiver"})
+
+	// CardActions 卡片按钮回调的处理结果，action 为 ack 或 silence，result 为 success 或 failure
+	CardActions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "card_actions_total",
+		Help:      "Card button callbacks handled, by action and result.",
+	}, []string{"receiver", "action", "result"})
+)
+
+// Handler 返回 /metrics 的 HTTP 处理器
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
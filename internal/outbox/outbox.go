@@ -0,0 +1,306 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	pendingBucket    = []byte("pending")
+	deadLetterBucket = []byte("deadletters")
+	cardBucket       = []byte("cards")
+	// cardIDBucket 消息 ID 到 cardBucket 中的键
+	cardIDBucket = []byte("card_ids")
+)
+
+// ErrNotFound 指定的消息不存在
+var ErrNotFound = errors.New("消息不存在")
+
+// Entry 一条已渲染、尚未确认发送成功的消息
+type Entry struct {
+	ID        string            `json:"id"`
+	Receiver  string            `json:"receiver"`
+	GroupKey  string            `json:"group_key,omitempty"`
+	Message   string            `json:"message"`
+	Headers   map[string]string `json:"headers,omitempty"` // generic 接收器渲染后的请求头
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// DeadLetter 重试耗尽后仍未发送成功的消息
+type DeadLetter struct {
+	Entry
+	Error    string    `json:"error"`
+	Response string    `json:"response,omitempty"` // 最后一次尝试时服务端返回的内容
+	FailedAt time.Time `json:"failed_at"`
+	Replays  int       `json:"replays"` // 通过 API 重新发送的次数
+}
+
+// Card 飞书应用机器人发送的一张卡片，告警恢复或点击卡片按钮时用于更新原来的卡片
+type Card struct {
+	Key       string          `json:"key"` // 接收器名称和告警 fingerprint
+	MessageID string          `json:"message_id"`
+	ChatID    string          `json:"chat_id"`
+	Content   json.RawMessage `json:"content"`
+	SentAt    time.Time       `json:"sent_at"`
+	// ExternalURL 发送卡片时通知中的 Alertmanager 地址，卡片按钮创建静默时使用
+	ExternalURL string `json:"external_url,omitempty"`
+}
+
+// Store 基于 bbolt 的持久化发件箱，消息在发送前写入，发送成功后删除，重试耗尽后移入死信
+type Store struct {
+	db *bolt.DB
+}
+
+// Open 打开或创建发件箱文件
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开发件箱 %s 失败: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{pendingBucket, deadLetterBucket, cardBucket, cardIDBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Put 持久化一条消息并为其分配递增的 ID
+func (s *Store) Put(entry *Entry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pendingBucket)
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		// 补零使 ID 的字典序与写入顺序一致
+		entry.ID = fmt.Sprintf("%020d", seq)
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(entry.ID), data)
+	})
+}
+
+// Delete 删除已发送成功的消息
+func (s *Store) Delete(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(pendingBucket).Delete([]byte(id))
+	})
+}
+
+// Pending 按写入顺序返回所有尚未发送成功的消息
+func (s *Store) Pending() ([]Entry, error) {
+	var entries []Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(pendingBucket).ForEach(func(k, v []byte) error {
+			var entry Entry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return fmt.Errorf("解析发件箱消息 %s 失败: %w", k, err)
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	return entries, err
+}
+
+// Bury 将消息从待发送列表移入死信
+func (s *Store) Bury(deadLetter *DeadLetter) error {
+	if deadLetter.FailedAt.IsZero() {
+		deadLetter.FailedAt = time.Now()
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(pendingBucket).Delete([]byte(deadLetter.ID)); err != nil {
+			return err
+		}
+		return putDeadLetter(tx, deadLetter)
+	})
+}
+
+// UpdateDeadLetter 更新死信的错误信息，例如重新发送再次失败后
+func (s *Store) UpdateDeadLetter(deadLetter *DeadLetter) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(deadLetterBucket).Get([]byte(deadLetter.ID)) == nil {
+			return ErrNotFound
+		}
+		return putDeadLetter(tx, deadLetter)
+	})
+}
+
+// DeadLetters 按写入顺序返回所有死信
+func (s *Store) DeadLetters() ([]DeadLetter, error) {
+	var deadLetters []DeadLetter
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(deadLetterBucket).ForEach(func(k, v []byte) error {
+			var deadLetter DeadLetter
+			if err := json.Unmarshal(v, &deadLetter); err != nil {
+				return fmt.Errorf("解析死信 %s 失败: %w", k, err)
+			}
+			deadLetters = append(deadLetters, deadLetter)
+			return nil
+		})
+	})
+	return deadLetters, err
+}
+
+// DeadLetter 返回指定 ID 的死信
+func (s *Store) DeadLetter(id string) (*DeadLetter, error) {
+	var deadLetter DeadLetter
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(deadLetterBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &deadLetter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &deadLetter, nil
+}
+
+// DeleteDeadLetter 删除指定 ID 的死信
+func (s *Store) DeleteDeadLetter(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(deadLetterBucket)
+		if bucket.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// PutCard 保存卡片，替换同一个键之前保存的卡片
+func (s *Store) PutCard(card *Card) error {
+	data, err := json.Marshal(card)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := deleteCard(tx, card.Key); err != nil {
+			return err
+		}
+		if err := tx.Bucket(cardIDBucket).Put([]byte(card.MessageID), []byte(card.Key)); err != nil {
+			return err
+		}
+		return tx.Bucket(cardBucket).Put([]byte(card.Key), data)
+	})
+}
+
+// Card 返回指定键的卡片
+func (s *Store) Card(key string) (*Card, error) {
+	var card Card
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(cardBucket).Get([]byte(key))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &card)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
+// CardByMessageID 返回指定消息 ID 的卡片
+func (s *Store) CardByMessageID(messageID string) (*Card, error) {
+	var card Card
+	err := s.db.View(func(tx *bolt.Tx) error {
+		key := tx.Bucket(cardIDBucket).Get([]byte(messageID))
+		if key == nil {
+			return ErrNotFound
+		}
+		data := tx.Bucket(cardBucket).Get(key)
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &card)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
+// DeleteCard 删除指定键的卡片，卡片不存在时不返回错误
+func (s *Store) DeleteCard(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return deleteCard(tx, key)
+	})
+}
+
+// PruneCards 删除 before 之前发送的卡片
+func (s *Store) PruneCards(before time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		var expired []string
+		err := tx.Bucket(cardBucket).ForEach(func(k, v []byte) error {
+			var card Card
+			if err := json.Unmarshal(v, &card); err != nil || card.SentAt.Before(before) {
+				expired = append(expired, string(k))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range expired {
+			if err := deleteCard(tx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func deleteCard(tx *bolt.Tx, key string) error {
+	bucket := tx.Bucket(cardBucket)
+	data := bucket.Get([]byte(key))
+	if data == nil {
+		return nil
+	}
+	var card Card
+	if err := json.Unmarshal(data, &card); err == nil {
+		if err := tx.Bucket(cardIDBucket).Delete([]byte(card.MessageID)); err != nil {
+			return err
+		}
+	}
+	return bucket.Delete([]byte(key))
+}
+
+func putDeadLetter(tx *bolt.Tx, deadLetter *DeadLetter) error {
+	data, err := json.Marshal(deadLetter)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(deadLetterBucket).Put([]byte(deadLetter.ID), data)
+}
+
+// Close 关闭发件箱文件
+func (s *Store) Close() error {
+	return s.db.Close()
+}
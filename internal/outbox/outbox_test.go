@@ -0,0 +1,203 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T, path string) *Store {
+	t.Helper()
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return store
+}
+
+func pendingMessages(t *testing.T, store *Store) []string {
+	t.Helper()
+	entries, err := store.Pending()
+	if err != nil {
+		t.Fatalf("Pending() error = %v", err)
+	}
+	var messages []string
+	for _, entry := range entries {
+		messages = append(messages, entry.Message)
+	}
+	return messages
+}
+
+func TestPutAssignsOrderedIDs(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer store.Close()
+
+	var ids []string
+	for i := 0; i < 12; i++ {
+		entry := Entry{Receiver: "ops", Message: string(rune('a' + i))}
+		if err := store.Put(&entry); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		if entry.ID == "" || entry.CreatedAt.IsZero() {
+			t.Fatalf("Put() did not fill ID and CreatedAt: %+v", entry)
+		}
+		ids = append(ids, entry.ID)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("ID %q is not after %q, want IDs ordered by write", ids[i], ids[i-1])
+		}
+	}
+
+	// 第 10 条之后仍然按写入顺序返回，而不是按字符串 "10" < "9" 排序
+	got := pendingMessages(t, store)
+	if len(got) != 12 || got[0] != "a" || got[9] != "j" || got[11] != "l" {
+		t.Errorf("Pending() = %v, want messages in write order", got)
+	}
+}
+
+func TestDeleteAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "outbox.db")
+	store := openTestStore(t, path)
+
+	first := Entry{Receiver: "ops", Message: "first", Headers: map[string]string{"X-Status": "firing"}}
+	second := Entry{Receiver: "dba", Message: "second"}
+	for _, entry := range []*Entry{&first, &second} {
+		if err := store.Put(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Delete(first.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开后仍能读到未删除的消息，新消息的 ID 继续递增
+	store = openTestStore(t, path)
+	defer store.Close()
+	entries, err := store.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID != second.ID || entries[0].Receiver != "dba" || entries[0].Message != "second" {
+		t.Fatalf("Pending() after reopen = %+v, want only the second entry", entries)
+	}
+
+	third := Entry{Receiver: "ops", Message: "third", Headers: map[string]string{"X-Status": "resolved"}}
+	if err := store.Put(&third); err != nil {
+		t.Fatal(err)
+	}
+	if third.ID <= second.ID {
+		t.Errorf("ID after reopen = %q, want it after %q", third.ID, second.ID)
+	}
+	entries, _ = store.Pending()
+	if len(entries) != 2 || entries[1].Headers["X-Status"] != "resolved" {
+		t.Errorf("Pending() = %+v, want headers persisted", entries)
+	}
+}
+
+func TestBuryAndDeadLetters(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer store.Close()
+
+	var entries []Entry
+	for _, receiver := range []string{"ops", "dba"} {
+		entry := Entry{Receiver: receiver, Message: "告警 " + receiver}
+		if err := store.Put(&entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+
+	deadLetter := DeadLetter{Entry: entries[0], Error: "状态码: 500", Response: `{"code":500}`}
+	if err := store.Bury(&deadLetter); err != nil {
+		t.Fatalf("Bury() error = %v", err)
+	}
+	if deadLetter.FailedAt.IsZero() {
+		t.Error("Bury() did not set FailedAt")
+	}
+	if got := pendingMessages(t, store); len(got) != 1 || got[0] != "告警 dba" {
+		t.Errorf("Pending() = %v, want the buried message removed", got)
+	}
+
+	deadLetters, err := store.DeadLetters()
+	if err != nil || len(deadLetters) != 1 {
+		t.Fatalf("DeadLetters() = %+v, %v", deadLetters, err)
+	}
+	if got := deadLetters[0]; got.ID != entries[0].ID || got.Receiver != "ops" || got.Response != `{"code":500}` {
+		t.Errorf("DeadLetters()[0] = %+v", got)
+	}
+
+	deadLetter.Replays++
+	deadLetter.Error = "状态码: 502"
+	if err := store.UpdateDeadLetter(&deadLetter); err != nil {
+		t.Fatalf("UpdateDeadLetter() error = %v", err)
+	}
+	got, err := store.DeadLetter(deadLetter.ID)
+	if err != nil || got.Replays != 1 || got.Error != "状态码: 502" {
+		t.Errorf("DeadLetter() = %+v, %v, want the updated dead letter", got, err)
+	}
+
+	if err := store.DeleteDeadLetter(deadLetter.ID); err != nil {
+		t.Fatalf("DeleteDeadLetter() error = %v", err)
+	}
+	if _, err := store.DeadLetter(deadLetter.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeadLetter() after delete error = %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteDeadLetter(deadLetter.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteDeadLetter() twice error = %v, want ErrNotFound", err)
+	}
+	if err := store.UpdateDeadLetter(&deadLetter); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateDeadLetter() after delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestCards(t *testing.T) {
+	store := openTestStore(t, filepath.Join(t.TempDir(), "outbox.db"))
+	defer store.Close()
+
+	now := time.Now()
+	put := func(key, messageID string, sentAt time.Time) {
+		t.Helper()
+		card := Card{Key: key, MessageID: messageID, ChatID: "oc_1", Content: json.RawMessage(`{}`), SentAt: sentAt,
+			ExternalURL: "http://alertmanager:9093"}
+		if err := store.PutCard(&card); err != nil {
+			t.Fatalf("PutCard() error = %v", err)
+		}
+	}
+	put("ops/fp1", "om_1", now)
+	// 同一个键的新卡片替换旧卡片，旧消息 ID 不再能找到
+	put("ops/fp1", "om_2", now)
+	if _, err := store.CardByMessageID("om_1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CardByMessageID(om_1) error = %v, want ErrNotFound", err)
+	}
+	card, err := store.CardByMessageID("om_2")
+	if err != nil || card.Key != "ops/fp1" || card.ExternalURL != "http://alertmanager:9093" {
+		t.Fatalf("CardByMessageID(om_2) = %+v, %v", card, err)
+	}
+
+	put("ops/fp2", "om_3", now.Add(-48*time.Hour))
+	if err := store.PruneCards(now.Add(-24 * time.Hour)); err != nil {
+		t.Fatalf("PruneCards() error = %v", err)
+	}
+	if _, err := store.Card("ops/fp2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Card(ops/fp2) error = %v, want the expired card to be pruned", err)
+	}
+	if _, err := store.CardByMessageID("om_3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CardByMessageID(om_3) error = %v, want ErrNotFound", err)
+	}
+
+	if err := store.DeleteCard("ops/fp1"); err != nil {
+		t.Fatalf("DeleteCard() error = %v", err)
+	}
+	if _, err := store.Card("ops/fp1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Card(ops/fp1) error = %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteCard("ops/fp1"); err != nil {
+		t.Errorf("DeleteCard() of a missing card error = %v", err)
+	}
+}
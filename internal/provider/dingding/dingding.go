@@ -8,21 +8,33 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"prometheus-webhook/internal/delivery"
+	"prometheus-webhook/internal/logging"
 	"prometheus-webhook/models"
+	"strings"
 	"time"
 )
 
+// retryableCodes 可以重试的钉钉错误码: -1 系统繁忙
+// 其余错误码 (签名不匹配、关键词不匹配、IP 不在白名单、token 不存在等) 重试也不会成功
+var retryableCodes = map[int]bool{-1: true}
+
+// rateLimitCode 发送速度太快被限流，钉钉按分钟统计发送次数，等待下一个统计周期再发送
+const (
+	rateLimitCode    = 130101
+	rateLimitBackoff = time.Minute
+)
+
 type Service struct {
-	httpClient *http.Client
+	client *delivery.Client
 }
 
 func NewService() *Service {
 	return &Service{
-		httpClient: &http.Client{},
+		client: delivery.NewClient(),
 	}
 }
 
@@ -37,62 +49,60 @@ func (s *Service) SendMessage(providerConfig models.WebhookProvider, message str
 		return err
 	}
 
-	webhookURL := providerConfig.WebhookURL
-	if providerConfig.Secret != "" {
-		timestamp := time.Now().UnixNano() / 1e6
-		signature := s.generateSignature(providerConfig.Secret, timestamp)
-		webhookURL = fmt.Sprintf("%s&timestamp=%d&sign=%s", webhookURL, timestamp, signature)
-	}
-
-	for i := 0; i < providerConfig.RetryCount; i++ {
-		ctx, cancel := context.WithTimeout(context.Background(), providerConfig.Timeout)
-		defer cancel()
-
-		req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(jsonData))
-		if err != nil {
-			return fmt.Errorf("创建请求失败: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := s.httpClient.Do(req)
-		if err != nil {
-			log.Printf("发送钉钉消息失败 (尝试 %d/%d): %v", i+1, providerConfig.RetryCount, err)
-			if i < providerConfig.RetryCount-1 {
-				time.Sleep(time.Second * time.Duration(i+1))
+	err = s.client.Send(delivery.NewPolicy(providerConfig), delivery.Request{
+		Name: "钉钉消息",
+		Build: func(ctx context.Context) (*http.Request, error) {
+			// 每次尝试重新签名，避免重试时时间戳过期
+			webhookURL := providerConfig.WebhookURL
+			if providerConfig.Secret != "" {
+				timestamp := time.Now().UnixNano() / 1e6
+				signature := s.generateSignature(providerConfig.Secret, timestamp)
+				webhookURL = fmt.Sprintf("%s&timestamp=%d&sign=%s", webhookURL, timestamp, signature)
 			}
-			continue
-		}
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("读取响应失败 (尝试 %d/%d): %v", i+1, providerConfig.RetryCount, err)
-			continue
-		}
-
-		if resp.StatusCode == http.StatusOK {
-			var result map[string]interface{}
-			if err := json.Unmarshal(body, &result); err != nil {
-				log.Printf("解析响应失败 (尝试 %d/%d): %v", i+1, providerConfig.RetryCount, err)
-				continue
+			req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(jsonData))
+			if err != nil {
+				return nil, err
 			}
+			req.Header.Set("Content-Type", "application/json")
+			return req, nil
+		},
+		Classify: classify,
+	})
+	if err != nil {
+		return err
+	}
 
-			if errcode, ok := result["errcode"].(float64); ok && errcode == 0 {
-				log.Printf("钉钉消息发送成功到: %s", providerConfig.WebhookURL)
-				return nil
-			} else {
-				log.Printf("钉钉API返回错误 (尝试 %d/%d): %v", i+1, providerConfig.RetryCount, result)
-			}
-		} else {
-			log.Printf("发送钉钉消息失败 (尝试 %d/%d), 状态码: %d, 响应: %s", i+1, providerConfig.RetryCount, resp.StatusCode, string(body))
-		}
+	slog.Info("钉钉消息发送成功", "receiver", providerConfig.ReceiverName, logging.KeyURL, providerConfig.WebhookURL)
+	return nil
+}
 
-		if i < providerConfig.RetryCount-1 {
-			time.Sleep(time.Second * time.Duration(i+1))
-		}
+func classify(resp *http.Response, body []byte) error {
+	if resp.StatusCode != http.StatusOK {
+		return delivery.StatusError(resp, body)
+	}
+
+	var result struct {
+		ErrCode *int   `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析响应失败: %w, 响应: %s", err, string(body))
+	}
+	if result.ErrCode == nil {
+		return fmt.Errorf("响应中没有 errcode, 响应: %s", string(body))
+	}
+	if *result.ErrCode == 0 {
+		return nil
 	}
 
-	return fmt.Errorf("发送钉钉消息失败，重试 %d 次后仍然失败", providerConfig.RetryCount)
+	err := &delivery.ProviderError{Provider: "钉钉", Code: *result.ErrCode, Message: result.ErrMsg}
+	if *result.ErrCode == rateLimitCode {
+		return delivery.RetryAfter(err, rateLimitBackoff)
+	}
+	if retryableCodes[*result.ErrCode] {
+		return err
+	}
+	return delivery.Permanent(err)
 }
 
 func (s *Service) generateSignature(secret string, timestamp int64) string {
@@ -102,3 +112,52 @@ func (s *Service) generateSignature(secret string, timestamp int64) string {
 	signature := base64.StdEncoding.EncodeToString(h.Sum(nil))
 	return url.QueryEscape(signature)
 }
+
+// MergeMentions 将需要 @ 的人合并到消息的 at 中，并在 markdown 和 text 消息的正文末尾添加 @ 文本，
+// 钉钉只有正文中包含 @手机号 或 @userId 时才会高亮显示被 @ 的人
+func (s *Service) MergeMentions(message string, mentions models.Mentions, _ map[string]models.Mentions) (string, error) {
+	var dingTalkMsg map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &dingTalkMsg); err != nil {
+		return "", fmt.Errorf("解析模板JSON失败: %w", err)
+	}
+
+	at, _ := dingTalkMsg["at"].(map[string]interface{})
+	if at == nil {
+		at = make(map[string]interface{})
+	}
+	models.MergeMentionList(at, "atMobiles", mentions.Mobiles)
+	models.MergeMentionList(at, "atUserIds", mentions.UserIDs)
+	if mentions.AtAll {
+		at["isAtAll"] = true
+	}
+	dingTalkMsg["at"] = at
+
+	var tags []string
+	for _, id := range append(append([]string{}, mentions.Mobiles...), mentions.UserIDs...) {
+		tags = append(tags, "@"+id)
+	}
+	if len(tags) > 0 {
+		switch dingTalkMsg["msgtype"] {
+		case "markdown":
+			appendText(dingTalkMsg, "markdown", "text", "\n\n"+strings.Join(tags, " "))
+		case "text":
+			appendText(dingTalkMsg, "text", "content", "\n"+strings.Join(tags, " "))
+		}
+	}
+
+	jsonData, err := json.Marshal(dingTalkMsg)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// appendText 在 msg[section][field] 的文本末尾追加内容
+func appendText(msg map[string]interface{}, section, field, text string) {
+	content, ok := msg[section].(map[string]interface{})
+	if !ok {
+		return
+	}
+	current, _ := content[field].(string)
+	content[field] = current + text
+}
@@ -0,0 +1,40 @@
+package dingding
+
+import (
+	"testing"
+
+	"prometheus-webhook/models"
+)
+
+func TestMergeMentions(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		mentions models.Mentions
+		want     string
+	}{
+		{
+			name:     "合并模板中已有的 at 并在正文末尾添加 @",
+			message:  `{"msgtype":"markdown","markdown":{"title":"告警","text":"磁盘"},"at":{"atMobiles":["138"]}}`,
+			mentions: models.Mentions{Mobiles: []string{"138", "139"}, UserIDs: []string{"u1"}},
+			want:     `{"at":{"atMobiles":["138","139"],"atUserIds":["u1"]},"markdown":{"text":"磁盘\n\n@138 @139 @u1","title":"告警"},"msgtype":"markdown"}`,
+		},
+		{
+			name:     "只 @所有人时不设置空列表",
+			message:  `{"msgtype":"text","text":{"content":"磁盘"}}`,
+			mentions: models.Mentions{AtAll: true},
+			want:     `{"at":{"isAtAll":true},"msgtype":"text","text":{"content":"磁盘"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewService().MergeMentions(tt.message, tt.mentions, nil)
+			if err != nil {
+				t.Fatalf("MergeMentions() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("MergeMentions() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"prometheus-webhook/internal/delivery"
+	"prometheus-webhook/models"
+)
+
+type Service struct {
+	client *delivery.Client
+}
+
+func NewService() *Service {
+	return &Service{
+		client: delivery.NewClient(),
+	}
+}
+
+// SendMessage 将模板渲染出的主题、纯文本和 HTML 正文组装为 multipart 邮件并通过 SMTP 发送
+func (s *Service) SendMessage(providerConfig models.WebhookProvider, message string) error {
+	var emailMsg models.EmailMessage
+	if err := json.Unmarshal([]byte(message), &emailMsg); err != nil {
+		return fmt.Errorf("解析模板JSON失败: %w", err)
+	}
+
+	cfg := providerConfig.Email
+	data, err := buildMessage(cfg, emailMsg)
+	if err != nil {
+		return fmt.Errorf("构造邮件失败: %w", err)
+	}
+
+	err = s.client.Retry(delivery.NewPolicy(providerConfig), "邮件", func(ctx context.Context) (*delivery.Response, error) {
+		err := s.send(ctx, cfg, data)
+		// SMTP 5xx 为永久性错误 (收件人不存在、认证失败等)，重试也不会成功
+		var protoErr *textproto.Error
+		if errors.As(err, &protoErr) {
+			resp := &delivery.Response{StatusCode: protoErr.Code, Body: protoErr.Msg}
+			if protoErr.Code >= 500 {
+				return resp, delivery.Permanent(err)
+			}
+			return resp, err
+		}
+		return nil, err
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("邮件发送成功", "receiver", providerConfig.ReceiverName, "to", strings.Join(recipients(cfg), ","))
+	return nil
+}
+
+// send 完成一次 SMTP 会话，整个会话共用 ctx 的截止时间
+func (s *Service) send(ctx context.Context, cfg models.EmailConfig, data []byte) error {
+	addr := net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: cfg.SMTPHost, InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	var conn net.Conn
+	var err error
+	if cfg.TLS == "tls" {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("连接SMTP服务器失败: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	client, err := smtp.NewClient(conn, cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if cfg.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP服务器 %s 不支持 STARTTLS", addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS 失败: %w", err)
+		}
+	}
+
+	if cfg.Username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("SMTP服务器 %s 不支持认证", addr)
+		}
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.SMTPHost)); err != nil {
+			return fmt.Errorf("SMTP认证失败: %w", err)
+		}
+	}
+
+	if err := client.Mail(addressOnly(cfg.From)); err != nil {
+		return err
+	}
+	for _, rcpt := range recipients(cfg) {
+		if err := client.Rcpt(addressOnly(rcpt)); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMessage 构造 multipart/alternative 邮件，BCC 不会出现在邮件头中
+func buildMessage(cfg models.EmailConfig, emailMsg models.EmailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	header("From", cfg.From)
+	if len(cfg.To) > 0 {
+		header("To", strings.Join(cfg.To, ", "))
+	}
+	if len(cfg.CC) > 0 {
+		header("Cc", strings.Join(cfg.CC, ", "))
+	}
+	header("Subject", mime.QEncoding.Encode("UTF-8", strings.TrimSpace(emailMsg.Subject)))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID(cfg.From))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/alternative; boundary="+writer.Boundary())
+	buf.WriteString("\r\n")
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=UTF-8", emailMsg.Text},
+		{"text/html; charset=UTF-8", emailMsg.HTML},
+	}
+	for _, p := range parts {
+		if strings.TrimSpace(p.body) == "" {
+			continue
+		}
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write([]byte(p.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func recipients(cfg models.EmailConfig) []string {
+	var all []string
+	all = append(all, cfg.To...)
+	all = append(all, cfg.CC...)
+	all = append(all, cfg.BCC...)
+	return all
+}
+
+// addressOnly 从 "Name <user@example.com>" 中提取邮件地址
+func addressOnly(address string) string {
+	if parsed, err := mail.ParseAddress(address); err == nil {
+		return parsed.Address
+	}
+	return address
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(addressOnly(from), "@"); at >= 0 {
+		domain = addressOnly(from)[at+1:]
+	}
+	random := make([]byte, 12)
+	rand.Read(random)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(random), domain)
+}
@@ -0,0 +1,230 @@
+package email
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"prometheus-webhook/internal/provider/providertest"
+	"prometheus-webhook/models"
+)
+
+// smtpStub 最简单的 SMTP 服务器，记录每次会话的发件人、收件人和邮件内容；
+// rcptReply 不为空时用它拒绝所有收件人
+type smtpStub struct {
+	listener  net.Listener
+	rcptReply string
+
+	mu       sync.Mutex
+	sessions int
+	from     string
+	rcpts    []string
+	data     string
+}
+
+func newSMTPStub(t *testing.T, rcptReply string) *smtpStub {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStub{listener: listener, rcptReply: rcptReply}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *smtpStub) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStub) handle(conn net.Conn) {
+	defer conn.Close()
+	s.mu.Lock()
+	s.sessions++
+	s.mu.Unlock()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 stub ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.Fields(line + " ")[0])
+		switch verb {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 stub")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = line
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			if s.rcptReply != "" {
+				tp.PrintfLine("%s", s.rcptReply)
+				continue
+			}
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, line)
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = string(data)
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("250 OK")
+		}
+	}
+}
+
+func testProvider(port int) models.WebhookProvider {
+	provider := providertest.Provider("")
+	provider.Timeout = 5 * time.Second
+	provider.Email = models.EmailConfig{
+		SMTPHost: "127.0.0.1",
+		SMTPPort: port,
+		From:     "Alertmanager <alert@example.com>",
+		To:       []string{"oncall@example.com"},
+		CC:       []string{"dba@example.com"},
+		BCC:      []string{"audit@example.com"},
+		TLS:      "none",
+	}
+	return provider
+}
+
+const testMessage = `{"subject":"[FIRING] 磁盘空间不足","text":"磁盘使用率 95%","html":"<b>磁盘使用率 95%</b>"}`
+
+func TestSendMessage(t *testing.T) {
+	stub := newSMTPStub(t, "")
+	if err := NewService().SendMessage(testProvider(stub.port()), testMessage); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if !strings.HasPrefix(stub.from, "MAIL FROM:<alert@example.com>") {
+		t.Errorf("MAIL = %q", stub.from)
+	}
+	wantRcpts := []string{"RCPT TO:<oncall@example.com>", "RCPT TO:<dba@example.com>", "RCPT TO:<audit@example.com>"}
+	if strings.Join(stub.rcpts, "|") != strings.Join(wantRcpts, "|") {
+		t.Errorf("RCPT = %v, want %v", stub.rcpts, wantRcpts)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(stub.data))
+	if err != nil {
+		t.Fatalf("ReadMessage() error = %v", err)
+	}
+	if msg.Header.Get("Bcc") != "" || strings.Contains(stub.data, "audit@example.com") {
+		t.Error("BCC recipient must not appear in the message headers")
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "[FIRING] 磁盘空间不足" {
+		t.Errorf("Subject = %q, err = %v", subject, err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, err = %v", msg.Header.Get("Content-Type"), err)
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	var parts []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(part)
+		parts = append(parts, part.Header.Get("Content-Type")+": "+string(body))
+	}
+	want := []string{
+		"text/plain; charset=UTF-8: 磁盘使用率 95%",
+		"text/html; charset=UTF-8: <b>磁盘使用率 95%</b>",
+	}
+	if strings.Join(parts, "|") != strings.Join(want, "|") {
+		t.Errorf("parts = %q, want %q", parts, want)
+	}
+}
+
+func TestSendMessageRejectedRecipient(t *testing.T) {
+	tests := []struct {
+		name         string
+		reply        string
+		wantSessions int
+	}{
+		{name: "5xx 不重试", reply: "550 mailbox unavailable", wantSessions: 1},
+		{name: "4xx 重试", reply: "451 try again later", wantSessions: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newSMTPStub(t, tt.reply)
+			if err := NewService().SendMessage(testProvider(stub.port()), testMessage); err == nil {
+				t.Fatal("SendMessage() error = nil, want error")
+			}
+			stub.mu.Lock()
+			defer stub.mu.Unlock()
+			if stub.sessions != tt.wantSessions {
+				t.Errorf("sessions = %d, want %d", stub.sessions, tt.wantSessions)
+			}
+		})
+	}
+}
+
+func TestSendMessageConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	cfg := testProvider(port)
+	cfg.RetryCount = 1
+	err = NewService().SendMessage(cfg, testMessage)
+	if err == nil || !strings.Contains(err.Error(), "连接SMTP服务器失败") {
+		t.Errorf("SendMessage() error = %v, want connection error", err)
+	}
+}
+
+func TestAddressOnly(t *testing.T) {
+	tests := map[string]string{
+		"Alertmanager <alert@example.com>": "alert@example.com",
+		"oncall@example.com":               "oncall@example.com",
+		"not an address":                   "not an address",
+	}
+	for input, want := range tests {
+		if got := addressOnly(input); got != want {
+			t.Errorf("addressOnly(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
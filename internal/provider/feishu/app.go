@@ -0,0 +1,246 @@
+package feishu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"prometheus-webhook/internal/delivery"
+	"prometheus-webhook/internal/outbox"
+	"prometheus-webhook/models"
+)
+
+// appRateLimitCode 开放平台应用调用频率超限
+const appRateLimitCode = 99991400
+
+// invalidTokenCodes tenant_access_token 缺失、无效或已过期
+var invalidTokenCodes = map[int]bool{
+	99991661: true,
+	99991663: true,
+	99991668: true,
+}
+
+// cardGoneCodes 更新卡片时表示原消息已不可更新的错误码，遇到这些错误时改为发送新卡片
+var cardGoneCodes = map[int]bool{
+	230011: true, // 消息已被撤回
+	230110: true, // 消息已被删除
+}
+
+// tokenRefreshMargin 在 tenant_access_token 过期前提前刷新，避免发送过程中过期
+const tokenRefreshMargin = 5 * time.Minute
+
+// tenantToken 缓存的 tenant_access_token，过期或 app_id 变化时重新获取
+// mu 只保护缓存的读写，获取令牌的请求不持有锁，请求失败时 classifyApp 会调用 invalidateToken
+type tenantToken struct {
+	mu        sync.Mutex
+	appID     string
+	value     string
+	expiresAt time.Time
+}
+
+// cardAlert 模板为卡片填写的告警信息
+type cardAlert struct {
+	fingerprint string
+	status      string
+	externalURL string
+}
+
+// sendApp 通过应用机器人发送一张卡片；告警恢复且之前发送过同一告警的卡片时更新原来的卡片
+func (s *Service) sendApp(policy delivery.Policy, providerConfig models.WebhookProvider, index int, feishuMsg models.FeishuInteractiveMessage, alert cardAlert) (int, error) {
+	cfg := providerConfig.FeishuApp
+	content, err := json.Marshal(feishuMsg.Card)
+	if err != nil {
+		return 0, fmt.Errorf("序列化第 %d 个飞书消息失败: %w", index, err)
+	}
+
+	receiver := providerConfig.ReceiverName
+	fingerprint, status := alert.fingerprint, alert.status
+	key := cardKey(receiver, fingerprint)
+	if fingerprint != "" && status == "resolved" {
+		if messageID, ok := s.cards.messageID(key, cfg.ChatID); ok {
+			attempts, err := s.updateCard(policy, cfg, index, messageID, string(content))
+			if err == nil {
+				s.cards.delete(receiver, key)
+				slog.Info("飞书卡片已更新为恢复状态", "receiver", providerConfig.ReceiverName, "fingerprint", fingerprint, "message_id", messageID)
+				return attempts, nil
+			}
+			if !cardGone(err) {
+				return attempts, err
+			}
+			// 消息已被撤回或删除，改为发送新的卡片
+			slog.Warn("更新飞书卡片失败, 改为发送新卡片", "receiver", providerConfig.ReceiverName, "fingerprint", fingerprint,
+				"message_id", messageID, "error", err)
+			s.cards.delete(receiver, key)
+			sent, _, err := s.createCard(policy, cfg, index, string(content))
+			return attempts + sent, err
+		}
+	}
+
+	attempts, messageID, err := s.createCard(policy, cfg, index, string(content))
+	if err == nil && fingerprint != "" && status != "resolved" && messageID != "" {
+		s.cards.put(receiver, &outbox.Card{Key: key, MessageID: messageID, ChatID: cfg.ChatID, Content: content, ExternalURL: alert.externalURL})
+	}
+	return attempts, err
+}
+
+// createCard 发送新的卡片消息，返回尝试次数和消息 ID
+func (s *Service) createCard(policy delivery.Policy, cfg models.FeishuAppConfig, index int, content string) (int, string, error) {
+	token, err := s.tenantAccessToken(policy, cfg)
+	if err != nil {
+		return 0, "", err
+	}
+	jsonData, err := json.Marshal(models.FeishuSendMessageRequest{
+		ReceiveID: cfg.ChatID,
+		MsgType:   "interactive",
+		Content:   content,
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	var result models.FeishuMessageResponse
+	apiURL := apiBaseURL(cfg) + "/open-apis/im/v1/messages?receive_id_type=chat_id"
+	attempts, err := s.client.SendWithAttempts(policy, delivery.Request{
+		Name:  fmt.Sprintf("第 %d 个飞书消息", index),
+		Build: buildAppRequest("POST", apiURL, token, jsonData),
+		Classify: func(resp *http.Response, body []byte) error {
+			return s.classifyApp(resp, body, &result)
+		},
+	})
+	return attempts, result.Data.MessageID, err
+}
+
+// updateCard 更新已发送的卡片消息，卡片需要在 config 中设置 update_multi 为 true
+func (s *Service) updateCard(policy delivery.Policy, cfg models.FeishuAppConfig, index int, messageID, content string) (int, error) {
+	token, err := s.tenantAccessToken(policy, cfg)
+	if err != nil {
+		return 0, err
+	}
+	jsonData, err := json.Marshal(models.FeishuUpdateMessageRequest{Content: content})
+	if err != nil {
+		return 0, err
+	}
+
+	apiURL := apiBaseURL(cfg) + "/open-apis/im/v1/messages/" + messageID
+	return s.client.SendWithAttempts(policy, delivery.Request{
+		Name:  fmt.Sprintf("更新第 %d 个飞书消息", index),
+		Build: buildAppRequest("PATCH", apiURL, token, jsonData),
+		Classify: func(resp *http.Response, body []byte) error {
+			return s.classifyApp(resp, body, nil)
+		},
+	})
+}
+
+// tenantAccessToken 返回缓存的 tenant_access_token，即将过期时重新获取
+func (s *Service) tenantAccessToken(policy delivery.Policy, cfg models.FeishuAppConfig) (string, error) {
+	s.token.mu.Lock()
+	if s.token.appID == cfg.AppID && s.token.value != "" && time.Now().Before(s.token.expiresAt) {
+		token := s.token.value
+		s.token.mu.Unlock()
+		return token, nil
+	}
+	s.token.mu.Unlock()
+
+	jsonData, err := json.Marshal(models.FeishuTenantTokenRequest{AppID: cfg.AppID, AppSecret: cfg.AppSecret})
+	if err != nil {
+		return "", err
+	}
+
+	var result models.FeishuTenantTokenResponse
+	apiURL := apiBaseURL(cfg) + "/open-apis/auth/v3/tenant_access_token/internal"
+	err = s.client.Send(policy, delivery.Request{
+		Name:  "获取飞书 tenant_access_token",
+		Build: buildAppRequest("POST", apiURL, "", jsonData),
+		Classify: func(resp *http.Response, body []byte) error {
+			return s.classifyApp(resp, body, &result)
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if result.TenantAccessToken == "" {
+		return "", fmt.Errorf("获取飞书 tenant_access_token 失败: 响应中没有 tenant_access_token")
+	}
+
+	// 并发获取时后完成的请求覆盖缓存，飞书在有效期内返回的是同一个令牌
+	s.token.mu.Lock()
+	defer s.token.mu.Unlock()
+	s.token.appID = cfg.AppID
+	s.token.value = result.TenantAccessToken
+	s.token.expiresAt = time.Now().Add(time.Duration(result.Expire)*time.Second - tokenRefreshMargin)
+	return s.token.value, nil
+}
+
+// invalidateToken 令牌被飞书拒绝时丢弃缓存，下次发送时重新获取
+func (s *Service) invalidateToken() {
+	s.token.mu.Lock()
+	defer s.token.mu.Unlock()
+	s.token.value = ""
+}
+
+// classifyApp 开放平台出错时 HTTP 状态码可能是 400，以响应中的 code 为准；成功时将响应解析到 out
+func (s *Service) classifyApp(resp *http.Response, body []byte, out interface{}) error {
+	var result struct {
+		Code *int   `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || result.Code == nil {
+		if resp.StatusCode != http.StatusOK {
+			return delivery.StatusError(resp, body)
+		}
+		return fmt.Errorf("响应中没有 code, 响应: %s", string(body))
+	}
+	if *result.Code == 0 {
+		if out != nil {
+			if err := json.Unmarshal(body, out); err != nil {
+				return fmt.Errorf("解析响应失败: %w", err)
+			}
+		}
+		return nil
+	}
+
+	err := &delivery.ProviderError{Provider: "飞书", Code: *result.Code, Message: result.Msg}
+	switch {
+	case *result.Code == appRateLimitCode || resp.StatusCode == http.StatusTooManyRequests:
+		return delivery.RetryAfter(err, rateLimitBackoff)
+	case invalidTokenCodes[*result.Code]:
+		// 本次使用的令牌已经写入请求，重新获取后由 Alertmanager 重试时使用
+		s.invalidateToken()
+		return delivery.Permanent(err)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return err
+	default:
+		return delivery.Permanent(err)
+	}
+}
+
+// cardGone 判断更新卡片失败是否因为原消息已被撤回或删除，其他错误发送新卡片也不会成功
+func cardGone(err error) bool {
+	var providerErr *delivery.ProviderError
+	return errors.As(err, &providerErr) && cardGoneCodes[providerErr.Code]
+}
+
+func buildAppRequest(method, apiURL, token string, jsonData []byte) func(ctx context.Context) (*http.Request, error) {
+	return func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, apiURL, bytes.NewBuffer(jsonData))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return req, nil
+	}
+}
+
+func apiBaseURL(cfg models.FeishuAppConfig) string {
+	return strings.TrimRight(cfg.APIBaseURL, "/")
+}
@@ -0,0 +1,281 @@
+package feishu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"prometheus-webhook/internal/outbox"
+	"prometheus-webhook/models"
+)
+
+// openAPIStub 模拟飞书开放平台的 tenant_access_token、发送消息和更新消息接口
+type openAPIStub struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	tokens  int
+	created []models.FeishuSendMessageRequest
+	updated []string // 被更新的消息 ID
+	auth    []string
+	// tokenCodes、sendCodes 和 updateCodes 依次作为获取令牌、发送和更新接口的 code 返回，用完后返回 0
+	tokenCodes  []int
+	sendCodes   []int
+	updateCodes []int
+}
+
+func newOpenAPIStub(t *testing.T) *openAPIStub {
+	t.Helper()
+	stub := &openAPIStub{}
+	stub.Server = httptest.NewServer(http.HandlerFunc(stub.handle))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+func (s *openAPIStub) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case r.URL.Path == "/open-apis/auth/v3/tenant_access_token/internal":
+		if code := next(&s.tokenCodes); code != 0 {
+			fmt.Fprintf(w, `{"code":%d,"msg":"error"}`, code)
+			return
+		}
+		s.tokens++
+		fmt.Fprintf(w, `{"code":0,"msg":"ok","tenant_access_token":"t-%d","expire":7200}`, s.tokens)
+	case r.Method == "POST" && r.URL.Path == "/open-apis/im/v1/messages":
+		s.auth = append(s.auth, r.Header.Get("Authorization"))
+		if code := next(&s.sendCodes); code != 0 {
+			fmt.Fprintf(w, `{"code":%d,"msg":"error"}`, code)
+			return
+		}
+		var req models.FeishuSendMessageRequest
+		json.Unmarshal(body, &req)
+		s.created = append(s.created, req)
+		fmt.Fprintf(w, `{"code":0,"msg":"ok","data":{"message_id":"om_%d"}}`, len(s.created))
+	case r.Method == "PATCH" && strings.HasPrefix(r.URL.Path, "/open-apis/im/v1/messages/"):
+		s.auth = append(s.auth, r.Header.Get("Authorization"))
+		if code := next(&s.updateCodes); code != 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"code":%d,"msg":"error"}`, code)
+			return
+		}
+		s.updated = append(s.updated, strings.TrimPrefix(r.URL.Path, "/open-apis/im/v1/messages/"))
+		io.WriteString(w, `{"code":0,"msg":"ok"}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func next(codes *[]int) int {
+	if len(*codes) == 0 {
+		return 0
+	}
+	code := (*codes)[0]
+	*codes = (*codes)[1:]
+	return code
+}
+
+func appProvider(receiver, url string) models.WebhookProvider {
+	return models.WebhookProvider{
+		ReceiverName: receiver,
+		Timeout:      time.Second,
+		RetryCount:   1,
+		Backoff:      models.BackoffConfig{InitialInterval: time.Millisecond, Multiplier: 1},
+		FeishuApp: models.FeishuAppConfig{
+			AppID:      "cli_test",
+			AppSecret:  "secret",
+			ChatID:     "oc_test",
+			APIBaseURL: url,
+		},
+	}
+}
+
+func cardMessage(fingerprint, status string) string {
+	return fmt.Sprintf(`{"msg_type":"interactive","fingerprint":%q,"status":%q,"card":{"config":{"update_multi":true},"elements":[{"tag":"div","text":{"tag":"lark_md","content":%q}}]}}`,
+		fingerprint, status, status)
+}
+
+func TestAppSendAndUpdateOnResolve(t *testing.T) {
+	stub := newOpenAPIStub(t)
+	service := NewService(nil)
+	cfg := appProvider("feishu-app", stub.URL)
+
+	if err := service.SendMessage(cfg, cardMessage("fp1", "firing")); err != nil {
+		t.Fatalf("firing: SendMessage() error = %v", err)
+	}
+	if err := service.SendMessage(cfg, cardMessage("fp1", "resolved")); err != nil {
+		t.Fatalf("resolved: SendMessage() error = %v", err)
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if stub.tokens != 1 {
+		t.Errorf("tenant_access_token requested %d times, want 1 (cached)", stub.tokens)
+	}
+	if len(stub.created) != 1 {
+		t.Fatalf("created %d cards, want 1", len(stub.created))
+	}
+	created := stub.created[0]
+	if created.ReceiveID != "oc_test" || created.MsgType != "interactive" {
+		t.Errorf("created = %+v", created)
+	}
+	if strings.Contains(created.Content, "fingerprint") || !strings.Contains(created.Content, "update_multi") {
+		t.Errorf("content = %s, want only the card", created.Content)
+	}
+	if len(stub.updated) != 1 || stub.updated[0] != "om_1" {
+		t.Errorf("updated = %v, want [om_1]", stub.updated)
+	}
+	for _, auth := range stub.auth {
+		if auth != "Bearer t-1" {
+			t.Errorf("Authorization = %q, want Bearer t-1", auth)
+		}
+	}
+}
+
+func TestAppResolveWithoutCardSendsNewCard(t *testing.T) {
+	stub := newOpenAPIStub(t)
+	service := NewService(nil)
+
+	// 另一个接收器发送的同一告警的卡片不能被更新
+	if err := service.SendMessage(appProvider("feishu-a", stub.URL), cardMessage("fp1", "firing")); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.SendMessage(appProvider("feishu-b", stub.URL), cardMessage("fp1", "resolved")); err != nil {
+		t.Fatal(err)
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.created) != 2 || len(stub.updated) != 0 {
+		t.Errorf("created %d, updated %v; want 2 new cards and no update", len(stub.created), stub.updated)
+	}
+}
+
+func TestAppUpdateFallsBackWhenCardGone(t *testing.T) {
+	stub := newOpenAPIStub(t)
+	stub.updateCodes = []int{230011} // 消息已被撤回
+	service := NewService(nil)
+	cfg := appProvider("feishu-app", stub.URL)
+
+	if err := service.SendMessage(cfg, cardMessage("fp1", "firing")); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.SendMessage(cfg, cardMessage("fp1", "resolved")); err != nil {
+		t.Fatalf("resolved: SendMessage() error = %v", err)
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.created) != 2 {
+		t.Errorf("created %d cards, want 2 (fallback to a new card)", len(stub.created))
+	}
+}
+
+func TestAppUpdateErrorDoesNotSendNewCard(t *testing.T) {
+	stub := newOpenAPIStub(t)
+	stub.updateCodes = []int{230099} // 卡片内容错误，发送新卡片也会失败
+	service := NewService(nil)
+	cfg := appProvider("feishu-app", stub.URL)
+
+	if err := service.SendMessage(cfg, cardMessage("fp1", "firing")); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.SendMessage(cfg, cardMessage("fp1", "resolved")); err == nil {
+		t.Fatal("resolved: SendMessage() error = nil, want the update error")
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.created) != 1 {
+		t.Errorf("created %d cards, want 1 (no fallback for other errors)", len(stub.created))
+	}
+}
+
+func TestAppTokenRequestRejected(t *testing.T) {
+	stub := newOpenAPIStub(t)
+	stub.tokenCodes = []int{99991663}
+	service := NewService(nil)
+	cfg := appProvider("feishu-app", stub.URL)
+
+	// 获取令牌的响应中的令牌错误码会丢弃缓存，不能因为持有令牌锁而死锁
+	done := make(chan error, 1)
+	go func() { done <- service.SendMessage(cfg, cardMessage("fp1", "firing")) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("SendMessage() error = nil, want token error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SendMessage() blocked after the token request was rejected")
+	}
+	if err := service.SendMessage(cfg, cardMessage("fp1", "firing")); err != nil {
+		t.Fatalf("retry: SendMessage() error = %v", err)
+	}
+}
+
+func TestAppInvalidTokenIsRefreshed(t *testing.T) {
+	stub := newOpenAPIStub(t)
+	stub.sendCodes = []int{99991663}
+	service := NewService(nil)
+	cfg := appProvider("feishu-app", stub.URL)
+
+	if err := service.SendMessage(cfg, cardMessage("fp1", "firing")); err == nil {
+		t.Fatal("SendMessage() error = nil, want invalid token error")
+	}
+	if err := service.SendMessage(cfg, cardMessage("fp1", "firing")); err != nil {
+		t.Fatalf("retry: SendMessage() error = %v", err)
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if stub.tokens != 2 {
+		t.Errorf("tenant_access_token requested %d times, want 2", stub.tokens)
+	}
+	if last := stub.auth[len(stub.auth)-1]; last != "Bearer t-2" {
+		t.Errorf("Authorization = %q, want the refreshed token", last)
+	}
+}
+
+func TestAppCardsPersistInOutbox(t *testing.T) {
+	stub := newOpenAPIStub(t)
+	store, err := outbox.Open(filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	cfg := appProvider("feishu-app", stub.URL)
+
+	if err := NewService(store).SendMessage(cfg, cardMessage("fp1", "firing")); err != nil {
+		t.Fatal(err)
+	}
+	// 模拟服务重启: 新的 Service 从发件箱中找到原来的卡片
+	restarted := NewService(store)
+	if _, ok := restarted.AnnotateCard("feishu-app", "om_1", "已确认"); !ok {
+		t.Error("AnnotateCard() ok = false after restart")
+	}
+	if _, ok := restarted.AnnotateCard("other", "om_1", "已确认"); ok {
+		t.Error("AnnotateCard() must not update cards of other receivers")
+	}
+	if err := restarted.SendMessage(cfg, cardMessage("fp1", "resolved")); err != nil {
+		t.Fatal(err)
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if len(stub.created) != 1 || len(stub.updated) != 1 {
+		t.Errorf("created %d, updated %v; want the original card to be updated", len(stub.created), stub.updated)
+	}
+	if _, err := store.Card(cardKey("feishu-app", "fp1")); err != outbox.ErrNotFound {
+		t.Errorf("Card() error = %v, want ErrNotFound after resolve", err)
+	}
+}
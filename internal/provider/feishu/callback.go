@@ -0,0 +1,158 @@
+package feishu
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"prometheus-webhook/models"
+)
+
+// ErrInvalidToken 回调中的 verification token 与配置不一致
+var ErrInvalidToken = errors.New("飞书回调的 verification token 不匹配")
+
+// CardAction 解密和校验后的卡片回调
+type CardAction struct {
+	// Challenge 非空时表示在开发者后台配置回调地址时发送的验证请求，需要原样返回
+	Challenge string
+	// V2 是否为 2.0 版本的回调，两个版本更新卡片的响应格式不同
+	V2         bool
+	OperatorID string // 点击按钮的用户的 open_id
+	MessageID  string
+	Value      models.FeishuActionValue
+}
+
+// ParseCallback 解密卡片回调并校验 verification token
+func ParseCallback(cfg models.FeishuAppConfig, body []byte) (*CardAction, error) {
+	var callback models.FeishuCardCallback
+	if err := json.Unmarshal(body, &callback); err != nil {
+		return nil, fmt.Errorf("解析回调失败: %w", err)
+	}
+	if callback.Encrypt != "" {
+		if cfg.EncryptKey == "" {
+			return nil, errors.New("回调已加密, 但没有配置 encrypt_key")
+		}
+		plain, err := decrypt(cfg.EncryptKey, callback.Encrypt)
+		if err != nil {
+			return nil, fmt.Errorf("解密回调失败: %w", err)
+		}
+		callback = models.FeishuCardCallback{}
+		if err := json.Unmarshal(plain, &callback); err != nil {
+			return nil, fmt.Errorf("解析解密后的回调失败: %w", err)
+		}
+	}
+
+	token := callback.Token
+	if callback.Schema == "2.0" {
+		token = callback.Header.Token
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(cfg.VerificationToken)) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	switch {
+	case callback.Type == "url_verification":
+		return &CardAction{Challenge: callback.Challenge}, nil
+	case callback.Schema == "2.0":
+		return &CardAction{
+			V2:         true,
+			OperatorID: callback.Event.Operator.OpenID,
+			MessageID:  callback.Event.Context.OpenMessageID,
+			Value:      callback.Event.Action.Value,
+		}, nil
+	default:
+		return &CardAction{
+			OperatorID: callback.OpenID,
+			MessageID:  callback.OpenMessageID,
+			Value:      callback.Action.Value,
+		}, nil
+	}
+}
+
+// CallbackResponse 返回回调的响应，card 不为空时飞书用它替换原来的卡片；
+// 旧版回调不支持提示，toast 只在 2.0 版本中显示
+func CallbackResponse(action *CardAction, card json.RawMessage, toast string, success bool) interface{} {
+	if !action.V2 {
+		if card == nil {
+			return map[string]interface{}{}
+		}
+		return card
+	}
+
+	toastType := "success"
+	if !success {
+		toastType = "error"
+	}
+	response := map[string]interface{}{
+		"toast": map[string]string{"type": toastType, "content": toast},
+	}
+	if card != nil {
+		response["card"] = map[string]interface{}{"type": "raw", "data": card}
+	}
+	return response
+}
+
+// AnnotateCard 在接收器的应用机器人发送的卡片末尾追加一条说明，返回更新后的卡片；
+// 卡片不是由该接收器发送、告警已恢复或未启用发件箱时服务重启后找不到卡片时返回 false
+func (s *Service) AnnotateCard(receiver, messageID, content string) (json.RawMessage, bool) {
+	return s.cards.annotate(receiver, messageID, content)
+}
+
+// CardExternalURL 返回接收器发送卡片时保存的 Alertmanager 地址，找不到卡片或没有保存地址时返回 false
+func (s *Service) CardExternalURL(receiver, messageID string) (string, bool) {
+	return s.cards.externalURL(receiver, messageID)
+}
+
+// appendElement 在卡片末尾追加一段 lark_md 文本，note 为 true 时在 1.0 卡片中使用备注样式；
+// 1.0 卡片的元素在 elements 中，2.0 卡片在 body.elements 中
+func appendElement(card interface{}, content string, note bool) bool {
+	c, ok := card.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if body, ok := c["body"].(map[string]interface{}); ok {
+		elements, _ := body["elements"].([]interface{})
+		body["elements"] = append(elements, map[string]interface{}{"tag": "markdown", "content": content})
+		return true
+	}
+
+	text := map[string]interface{}{"tag": "lark_md", "content": content}
+	element := map[string]interface{}{"tag": "div", "text": text}
+	if note {
+		element = map[string]interface{}{"tag": "note", "elements": []interface{}{text}}
+	}
+	elements, _ := c["elements"].([]interface{})
+	c["elements"] = append(elements, element)
+	return true
+}
+
+// decrypt 解密回调: 密钥为 encrypt_key 的 SHA-256，Base64 解码后前 16 字节为 IV，AES-256-CBC 解密后去掉 PKCS7 填充
+func decrypt(encryptKey, encrypted string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2*aes.BlockSize || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("密文长度无效")
+	}
+
+	key := sha256.Sum256([]byte(encryptKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	iv, data := data[:aes.BlockSize], data[aes.BlockSize:]
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, errors.New("填充无效, 请检查 encrypt_key")
+	}
+	return plain[:len(plain)-padding], nil
+}
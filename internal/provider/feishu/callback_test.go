@@ -0,0 +1,227 @@
+package feishu
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"prometheus-webhook/internal/outbox"
+	"prometheus-webhook/models"
+)
+
+const (
+	testVerificationToken = "verification-token"
+	testEncryptKey        = "encrypt-key"
+)
+
+// encrypt 按飞书的方式加密回调内容，用于构造测试数据
+func encrypt(t *testing.T, key, plain string) string {
+	t.Helper()
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	data := append([]byte(plain), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	out := make([]byte, aes.BlockSize+len(data))
+	iv := out[:aes.BlockSize]
+	if _, err := rand.Read(iv); err != nil {
+		t.Fatal(err)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out[aes.BlockSize:], data)
+	return base64.StdEncoding.EncodeToString(out)
+}
+
+func encryptedBody(t *testing.T, plain string) []byte {
+	body, _ := json.Marshal(map[string]string{"encrypt": encrypt(t, testEncryptKey, plain)})
+	return body
+}
+
+func callbackConfig() models.FeishuAppConfig {
+	return models.FeishuAppConfig{VerificationToken: testVerificationToken, EncryptKey: testEncryptKey}
+}
+
+const (
+	legacyCallback = `{"token":"verification-token","open_id":"ou_1","open_message_id":"om_1",
+		"action":{"value":{"action":"silence","duration":"4h","labels":{"alertname":"Disk"}}}}`
+	v2Callback = `{"schema":"2.0","header":{"token":"verification-token","event_type":"card.action.trigger"},
+		"event":{"operator":{"open_id":"ou_2"},"action":{"value":{"action":"ack"}},"context":{"open_message_id":"om_2","open_chat_id":"oc_1"}}}`
+)
+
+func TestDecrypt(t *testing.T) {
+	plain := `{"challenge":"abc"}`
+	got, err := decrypt(testEncryptKey, encrypt(t, testEncryptKey, plain))
+	if err != nil {
+		t.Fatalf("decrypt() error = %v", err)
+	}
+	if string(got) != plain {
+		t.Errorf("decrypt() = %q, want %q", got, plain)
+	}
+
+	tests := map[string]string{
+		"不是 base64":   "!!!",
+		"长度不足":        base64.StdEncoding.EncodeToString(make([]byte, aes.BlockSize)),
+		"长度不是块大小的整数倍": base64.StdEncoding.EncodeToString(make([]byte, aes.BlockSize*2+1)),
+	}
+	for name, input := range tests {
+		if _, err := decrypt(testEncryptKey, input); err == nil {
+			t.Errorf("%s: decrypt() error = nil", name)
+		}
+	}
+}
+
+func TestDecryptWrongKey(t *testing.T) {
+	encrypted := encrypt(t, "another-key", `{"challenge":"abc"}`)
+	plain, err := decrypt(testEncryptKey, encrypted)
+	if err == nil && json.Valid(plain) {
+		t.Errorf("decrypt() with the wrong key = %q, want an error or garbage", plain)
+	}
+}
+
+func TestParseCallback(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		want CardAction
+	}{
+		{
+			name: "URL 验证",
+			body: []byte(`{"type":"url_verification","token":"verification-token","challenge":"abc"}`),
+			want: CardAction{Challenge: "abc"},
+		},
+		{
+			name: "旧版回调",
+			body: []byte(legacyCallback),
+			want: CardAction{OperatorID: "ou_1", MessageID: "om_1", Value: models.FeishuActionValue{
+				Action: "silence", Duration: "4h", Labels: map[string]string{"alertname": "Disk"}}},
+		},
+		{
+			name: "2.0 回调",
+			body: []byte(v2Callback),
+			want: CardAction{V2: true, OperatorID: "ou_2", MessageID: "om_2", Value: models.FeishuActionValue{Action: "ack"}},
+		},
+		{
+			name: "加密的 2.0 回调",
+			body: encryptedBody(t, v2Callback),
+			want: CardAction{V2: true, OperatorID: "ou_2", MessageID: "om_2", Value: models.FeishuActionValue{Action: "ack"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCallback(callbackConfig(), tt.body)
+			if err != nil {
+				t.Fatalf("ParseCallback() error = %v", err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tt.want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("ParseCallback() = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestParseCallbackRejectsInvalidToken(t *testing.T) {
+	tests := map[string][]byte{
+		"旧版 token 错误":  []byte(strings.Replace(legacyCallback, testVerificationToken, "wrong", 1)),
+		"2.0 token 错误": []byte(strings.Replace(v2Callback, testVerificationToken, "wrong", 1)),
+		// 2.0 回调的 token 在 header 中，顶层的 token 不能代替
+		"2.0 只有顶层 token":  []byte(`{"schema":"2.0","token":"verification-token","event":{"action":{"value":{"action":"ack"}}}}`),
+		"URL 验证 token 错误": []byte(`{"type":"url_verification","token":"wrong","challenge":"abc"}`),
+		"没有 token":        []byte(`{"open_message_id":"om_1","action":{"value":{"action":"ack"}}}`),
+		"加密后 token 错误":    encryptedBody(t, strings.Replace(v2Callback, testVerificationToken, "wrong", 1)),
+	}
+	for name, body := range tests {
+		if _, err := ParseCallback(callbackConfig(), body); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: ParseCallback() error = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestParseCallbackErrors(t *testing.T) {
+	noKey := callbackConfig()
+	noKey.EncryptKey = ""
+	if _, err := ParseCallback(noKey, encryptedBody(t, v2Callback)); err == nil || errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ParseCallback() without encrypt_key error = %v", err)
+	}
+	if _, err := ParseCallback(callbackConfig(), []byte(`not json`)); err == nil {
+		t.Error("ParseCallback() error = nil for invalid JSON")
+	}
+}
+
+func TestCallbackResponse(t *testing.T) {
+	card := json.RawMessage(`{"elements":[]}`)
+
+	legacy := &CardAction{}
+	if got, _ := json.Marshal(CallbackResponse(legacy, card, "操作成功", true)); string(got) != string(card) {
+		t.Errorf("legacy response = %s, want the card", got)
+	}
+	if got, _ := json.Marshal(CallbackResponse(legacy, nil, "操作失败", false)); string(got) != `{}` {
+		t.Errorf("legacy response without card = %s, want {}", got)
+	}
+
+	v2 := &CardAction{V2: true}
+	got, _ := json.Marshal(CallbackResponse(v2, card, "操作成功", true))
+	want := `{"card":{"data":{"elements":[]},"type":"raw"},"toast":{"content":"操作成功","type":"success"}}`
+	if string(got) != want {
+		t.Errorf("v2 response = %s, want %s", got, want)
+	}
+	got, _ = json.Marshal(CallbackResponse(v2, nil, "操作失败", false))
+	if string(got) != `{"toast":{"content":"操作失败","type":"error"}}` {
+		t.Errorf("v2 error response = %s", got)
+	}
+}
+
+func TestAppendElement(t *testing.T) {
+	var v1 interface{}
+	json.Unmarshal([]byte(`{"elements":[{"tag":"div"}]}`), &v1)
+	if !appendElement(v1, "已确认", true) {
+		t.Fatal("appendElement() = false for a 1.0 card")
+	}
+	got, _ := json.Marshal(v1)
+	if want := `{"elements":[{"tag":"div"},{"elements":[{"content":"已确认","tag":"lark_md"}],"tag":"note"}]}`; string(got) != want {
+		t.Errorf("1.0 card = %s, want %s", got, want)
+	}
+
+	var v2 interface{}
+	json.Unmarshal([]byte(`{"schema":"2.0","body":{"elements":[]}}`), &v2)
+	if !appendElement(v2, "已确认", true) {
+		t.Fatal("appendElement() = false for a 2.0 card")
+	}
+	got, _ = json.Marshal(v2)
+	if want := `{"body":{"elements":[{"content":"已确认","tag":"markdown"}]},"schema":"2.0"}`; string(got) != want {
+		t.Errorf("2.0 card = %s, want %s", got, want)
+	}
+
+	if appendElement([]interface{}{}, "已确认", true) {
+		t.Error("appendElement() = true for a non-object card")
+	}
+}
+
+func TestAnnotateCard(t *testing.T) {
+	service := NewService(nil)
+	service.cards.put("feishu-app", &outbox.Card{Key: cardKey("feishu-app", "fp1"), MessageID: "om_1", ChatID: "oc_1",
+		Content: json.RawMessage(`{"elements":[]}`)})
+
+	card, ok := service.AnnotateCard("feishu-app", "om_1", "✅ 已确认")
+	if !ok || !strings.Contains(string(card), "✅ 已确认") {
+		t.Fatalf("AnnotateCard() = %s, %v", card, ok)
+	}
+	// 第二次操作在第一次的基础上追加
+	card, _ = service.AnnotateCard("feishu-app", "om_1", "🔕 已静默")
+	if !strings.Contains(string(card), "✅ 已确认") || !strings.Contains(string(card), "🔕 已静默") {
+		t.Errorf("AnnotateCard() = %s, want both notes", card)
+	}
+	if _, ok := service.AnnotateCard("feishu-app", "om_unknown", "x"); ok {
+		t.Error("AnnotateCard() ok = true for an unknown message")
+	}
+}
@@ -0,0 +1,200 @@
+package feishu
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"prometheus-webhook/internal/outbox"
+)
+
+// cardTTL 卡片的保存时间，超过后告警恢复时发送新的卡片；飞书只允许更新 14 天内发送的消息
+const cardTTL = 14 * 24 * time.Hour
+
+// cardPruneInterval 清理过期卡片的最短间隔，清理需要遍历全部卡片，不在每次发送时进行
+const cardPruneInterval = time.Hour
+
+// cardBackend 保存卡片的存储，启用发件箱时使用发件箱文件，服务重启后仍然可以更新原来的卡片
+type cardBackend interface {
+	PutCard(card *outbox.Card) error
+	Card(key string) (*outbox.Card, error)
+	CardByMessageID(messageID string) (*outbox.Card, error)
+	DeleteCard(key string) error
+	PruneCards(before time.Time) error
+}
+
+// cardStore 保存应用机器人发送的卡片，键为接收器名称和告警 fingerprint；
+// 卡片内容用于处理按钮回调时更新卡片
+type cardStore struct {
+	// mu 保证追加说明时读取和写回卡片之间不会被其他回调覆盖
+	mu      sync.Mutex
+	backend cardBackend
+
+	pruneMu   sync.Mutex
+	lastPrune time.Time
+}
+
+func newCardStore(store *outbox.Store) *cardStore {
+	if store == nil {
+		return &cardStore{backend: newMemoryCards()}
+	}
+	return &cardStore{backend: store}
+}
+
+func cardKey(receiver, fingerprint string) string {
+	return receiver + "/" + fingerprint
+}
+
+// messageID 返回告警对应的卡片消息 ID，卡片已过期或发送到其他群组时返回 false
+func (c *cardStore) messageID(key, chatID string) (string, bool) {
+	card, err := c.backend.Card(key)
+	if err != nil {
+		if !errors.Is(err, outbox.ErrNotFound) {
+			slog.Warn("读取飞书卡片记录失败", "key", key, "error", err)
+		}
+		return "", false
+	}
+	if card.ChatID != chatID || time.Since(card.SentAt) > cardTTL {
+		return "", false
+	}
+	return card.MessageID, true
+}
+
+// put 记录最近一次发送的卡片，同时定期清理过期的记录；记录失败不影响发送结果
+func (c *cardStore) put(receiver string, card *outbox.Card) {
+	card.SentAt = time.Now()
+	c.prune(receiver, card.SentAt)
+	if err := c.backend.PutCard(card); err != nil {
+		slog.Warn("记录飞书卡片失败, 告警恢复时将发送新卡片", "receiver", receiver, "message_id", card.MessageID, "error", err)
+		return
+	}
+	slog.Debug("记录飞书卡片消息 ID", "receiver", receiver, "key", card.Key, "message_id", card.MessageID)
+}
+
+// prune 删除超过 cardTTL 的卡片，两次清理至少间隔 cardPruneInterval；
+// 过期但还没有清理的卡片在 messageID 和 annotate 中按 SentAt 判断，不会被使用
+func (c *cardStore) prune(receiver string, now time.Time) {
+	c.pruneMu.Lock()
+	if now.Sub(c.lastPrune) < cardPruneInterval {
+		c.pruneMu.Unlock()
+		return
+	}
+	c.lastPrune = now
+	c.pruneMu.Unlock()
+
+	if err := c.backend.PruneCards(now.Add(-cardTTL)); err != nil {
+		slog.Warn("清理过期的飞书卡片记录失败", "receiver", receiver, "error", err)
+	}
+}
+
+func (c *cardStore) delete(receiver, key string) {
+	if err := c.backend.DeleteCard(key); err != nil {
+		slog.Warn("删除飞书卡片记录失败", "receiver", receiver, "key", key, "error", err)
+	}
+}
+
+// externalURL 返回接收器发送的卡片保存的 Alertmanager 地址
+func (c *cardStore) externalURL(receiver, messageID string) (string, bool) {
+	card, err := c.backend.CardByMessageID(messageID)
+	if err != nil || !strings.HasPrefix(card.Key, receiver+"/") || card.ExternalURL == "" {
+		return "", false
+	}
+	return card.ExternalURL, true
+}
+
+// annotate 在接收器发送的卡片末尾追加一段文本，返回更新后的卡片 JSON
+func (c *cardStore) annotate(receiver, messageID, content string) (json.RawMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	card, err := c.backend.CardByMessageID(messageID)
+	if err != nil || !strings.HasPrefix(card.Key, receiver+"/") || time.Since(card.SentAt) > cardTTL {
+		return nil, false
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(card.Content, &body); err != nil || !appendElement(body, content, true) {
+		return nil, false
+	}
+	updated, err := json.Marshal(body)
+	if err != nil {
+		return nil, false
+	}
+	card.Content = updated
+	if err := c.backend.PutCard(card); err != nil {
+		slog.Warn("保存飞书卡片失败", "receiver", receiver, "message_id", messageID, "error", err)
+	}
+	return updated, true
+}
+
+// memoryCards 未启用发件箱时在内存中保存卡片，服务重启后丢失
+type memoryCards struct {
+	mu    sync.Mutex
+	cards map[string]outbox.Card
+	// byID 消息 ID 到 cards 中的键
+	byID map[string]string
+}
+
+func newMemoryCards() *memoryCards {
+	return &memoryCards{
+		cards: make(map[string]outbox.Card),
+		byID:  make(map[string]string),
+	}
+}
+
+func (m *memoryCards) PutCard(card *outbox.Card) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(card.Key)
+	m.cards[card.Key] = *card
+	m.byID[card.MessageID] = card.Key
+	return nil
+}
+
+func (m *memoryCards) Card(key string) (*outbox.Card, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	card, ok := m.cards[key]
+	if !ok {
+		return nil, outbox.ErrNotFound
+	}
+	return &card, nil
+}
+
+func (m *memoryCards) CardByMessageID(messageID string) (*outbox.Card, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	card, ok := m.cards[m.byID[messageID]]
+	if !ok {
+		return nil, outbox.ErrNotFound
+	}
+	return &card, nil
+}
+
+func (m *memoryCards) DeleteCard(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(key)
+	return nil
+}
+
+func (m *memoryCards) PruneCards(before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, card := range m.cards {
+		if card.SentAt.Before(before) {
+			m.remove(key)
+		}
+	}
+	return nil
+}
+
+// remove 删除记录，调用时需要持有锁
+func (m *memoryCards) remove(key string) {
+	if card, ok := m.cards[key]; ok {
+		delete(m.byID, card.MessageID)
+		delete(m.cards, key)
+	}
+}
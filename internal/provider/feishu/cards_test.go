@@ -0,0 +1,48 @@
+package feishu
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"prometheus-webhook/internal/outbox"
+)
+
+// countingCards 记录 PruneCards 的调用次数
+type countingCards struct {
+	*memoryCards
+	prunes int
+}
+
+func (c *countingCards) PruneCards(before time.Time) error {
+	c.prunes++
+	return c.memoryCards.PruneCards(before)
+}
+
+func TestCardStorePrunesPeriodically(t *testing.T) {
+	backend := &countingCards{memoryCards: newMemoryCards()}
+	cards := &cardStore{backend: backend}
+	put := func(fingerprint string) {
+		cards.put("feishu-app", &outbox.Card{Key: cardKey("feishu-app", fingerprint), MessageID: "om_" + fingerprint, Content: json.RawMessage(`{}`)})
+	}
+
+	// 过期的卡片在第一次记录卡片时被清理
+	backend.PutCard(&outbox.Card{Key: cardKey("feishu-app", "old"), MessageID: "om_old", SentAt: time.Now().Add(-cardTTL - time.Hour)})
+	for i := 0; i < 5; i++ {
+		put(fmt.Sprint(i))
+	}
+	if backend.prunes != 1 {
+		t.Errorf("PruneCards called %d times for 5 cards, want 1", backend.prunes)
+	}
+	if _, err := backend.Card(cardKey("feishu-app", "old")); err != outbox.ErrNotFound {
+		t.Errorf("Card(old) error = %v, want the expired card to be pruned", err)
+	}
+
+	// 超过清理间隔后再次清理
+	cards.lastPrune = time.Now().Add(-cardPruneInterval)
+	put("5")
+	if backend.prunes != 2 {
+		t.Errorf("PruneCards called %d times after the interval, want 2", backend.prunes)
+	}
+}
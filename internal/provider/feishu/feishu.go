@@ -3,26 +3,55 @@ package feishu
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
+	"prometheus-webhook/internal/delivery"
+	"prometheus-webhook/internal/logging"
+	"prometheus-webhook/internal/outbox"
 	"prometheus-webhook/models"
 )
 
+// rateLimitCode 发送频率过快被限流，飞书按秒和分钟统计发送次数
+// 其余错误码 (签名校验失败 19021、关键词不匹配、IP 不在白名单、消息格式错误等) 重试也不会成功
+const (
+	rateLimitCode    = 11232
+	rateLimitBackoff = 10 * time.Second
+)
+
+// progressTTL 部分卡片发送失败后，等待 Alertmanager 重试同一通知的最长时间
+const progressTTL = time.Hour
+
 type Service struct {
-	httpClient *http.Client
+	client   *delivery.Client
+	progress *delivery.Progress
+	// token 应用机器人模式下缓存的 tenant_access_token
+	token tenantToken
+	// cards 应用机器人模式下发送的卡片，用于告警恢复和按钮回调时更新原来的卡片
+	cards *cardStore
 }
 
-func NewService() *Service {
+// NewService 创建飞书发送服务，store 不为 nil 时应用机器人发送的卡片保存在发件箱中
+func NewService(store *outbox.Store) *Service {
 	return &Service{
-		httpClient: &http.Client{},
+		client:   delivery.NewClient(),
+		progress: delivery.NewProgress(progressTTL),
+		cards:    newCardStore(store),
 	}
 }
 
+// InheritCards 在重新加载配置后沿用旧 Service 保存的卡片，未启用发件箱时卡片只保存在内存中
+func (s *Service) InheritCards(old *Service) {
+	s.cards = old.cards
+}
+
 func (s *Service) SendMessage(providerConfig models.WebhookProvider, message string) error {
 	// 解析消息，可能是单个卡片或卡片数组
 	var feishuMessages []models.FeishuInteractiveMessage
@@ -37,75 +66,113 @@ func (s *Service) SendMessage(providerConfig models.WebhookProvider, message str
 		feishuMessages = []models.FeishuInteractiveMessage{singleMsg}
 	}
 
-	// 发送每个独立的卡片消息
+	// 发送每个独立的卡片消息，上次发送同一通知时已经成功的卡片不再重复发送
+	policy := delivery.NewPolicy(providerConfig)
+	delivered := s.progress.Delivered(providerConfig.ReceiverName, message)
+	results := make([]delivery.MessageResult, 0, len(feishuMessages))
+	target := []any{logging.KeyURL, providerConfig.WebhookURL}
+	if providerConfig.FeishuApp.Enabled() {
+		target = []any{"chat_id", providerConfig.FeishuApp.ChatID}
+	}
+	var firstErr error
 	for msgIndex, feishuMsg := range feishuMessages {
-		jsonData, err := json.Marshal(feishuMsg)
-		if err != nil {
-			log.Printf("序列化第 %d 个消息失败: %v", msgIndex+1, err)
+		result := delivery.MessageResult{Index: msgIndex + 1}
+		if delivered[result.Index] {
+			result.Success, result.Skipped = true, true
+			results = append(results, result)
 			continue
 		}
 
-		success := false
-		for i := 0; i < providerConfig.RetryCount; i++ {
-			ctx, cancel := context.WithTimeout(context.Background(), providerConfig.Timeout)
-			defer cancel()
-
-			req, err := http.NewRequestWithContext(ctx, "POST", providerConfig.WebhookURL, bytes.NewBuffer(jsonData))
-			if err != nil {
-				log.Printf("创建第 %d 个消息请求失败: %v", msgIndex+1, err)
-				break
+		attempts, err := s.send(policy, providerConfig, result.Index, feishuMsg)
+		result.Attempts = attempts
+		if err != nil {
+			slog.Error("飞书消息发送失败", "receiver", providerConfig.ReceiverName, "card", result.Index, "error", err)
+			result.Code = delivery.CodeOf(err)
+			result.Error = err.Error()
+			if firstErr == nil {
+				firstErr = err
 			}
-			req.Header.Set("Content-Type", "application/json")
+		} else {
+			result.Success = true
+			slog.Info("飞书消息发送成功", append([]any{"receiver", providerConfig.ReceiverName, "card", result.Index}, target...)...)
+		}
+		results = append(results, result)
+	}
 
-			resp, err := s.httpClient.Do(req)
-			if err != nil {
-				log.Printf("发送第 %d 个飞书消息失败 (尝试 %d/%d): %v", msgIndex+1, i+1, providerConfig.RetryCount, err)
-				if i < providerConfig.RetryCount-1 {
-					time.Sleep(time.Second * time.Duration(i+1))
+	s.progress.Record(providerConfig.ReceiverName, message, results)
+	if firstErr != nil {
+		return &delivery.BatchError{Name: "飞书消息", Results: results, Err: firstErr}
+	}
+	return nil
+}
+
+// send 发送一张卡片，返回尝试次数
+func (s *Service) send(policy delivery.Policy, providerConfig models.WebhookProvider, index int, feishuMsg models.FeishuInteractiveMessage) (int, error) {
+	// fingerprint、status 和 external_url 只用于关联卡片，不发送给飞书
+	alert := cardAlert{fingerprint: feishuMsg.Fingerprint, status: feishuMsg.Status, externalURL: feishuMsg.ExternalURL}
+	feishuMsg.Fingerprint, feishuMsg.Status, feishuMsg.ExternalURL = "", "", ""
+	if providerConfig.FeishuApp.Enabled() {
+		return s.sendApp(policy, providerConfig, index, feishuMsg, alert)
+	}
+
+	jsonData, err := json.Marshal(feishuMsg)
+	if err != nil {
+		return 0, fmt.Errorf("序列化第 %d 个飞书消息失败: %w", index, err)
+	}
+
+	return s.client.SendWithAttempts(policy, delivery.Request{
+		Name: fmt.Sprintf("第 %d 个飞书消息", index),
+		Build: func(ctx context.Context) (*http.Request, error) {
+			// 每次尝试重新签名，飞书只接受一小时内的时间戳
+			if providerConfig.Secret != "" {
+				timestamp := time.Now().Unix()
+				feishuMsg.Timestamp = strconv.FormatInt(timestamp, 10)
+				feishuMsg.Sign = s.generateSignature(providerConfig.Secret, timestamp)
+				if jsonData, err = json.Marshal(feishuMsg); err != nil {
+					return nil, err
 				}
-				continue
 			}
-			defer resp.Body.Close()
-
-			body, err := io.ReadAll(resp.Body)
+			req, err := http.NewRequestWithContext(ctx, "POST", providerConfig.WebhookURL, bytes.NewBuffer(jsonData))
 			if err != nil {
-				log.Printf("读取第 %d 个消息响应失败 (尝试 %d/%d): %v", msgIndex+1, i+1, providerConfig.RetryCount, err)
-				if i < providerConfig.RetryCount-1 {
-					time.Sleep(time.Second * time.Duration(i+1))
-				}
-				continue
+				return nil, err
 			}
+			req.Header.Set("Content-Type", "application/json")
+			return req, nil
+		},
+		Classify: classify,
+	})
+}
 
-			if resp.StatusCode == http.StatusOK {
-				var result map[string]interface{}
-				if err := json.Unmarshal(body, &result); err != nil {
-					log.Printf("解析第 %d 个消息响应失败 (尝试 %d/%d): %v", msgIndex+1, i+1, providerConfig.RetryCount, err)
-					if i < providerConfig.RetryCount-1 {
-						time.Sleep(time.Second * time.Duration(i+1))
-					}
-					continue
-				}
-				// 检查飞书返回的具体业务错误码
-				if code, ok := result["code"].(float64); ok && code == 0 {
-					log.Printf("第 %d 个飞书消息发送成功到: %s", msgIndex+1, providerConfig.WebhookURL)
-					success = true
-					break
-				} else {
-					log.Printf("第 %d 个飞书API返回错误 (尝试 %d/%d): %v", msgIndex+1, i+1, providerConfig.RetryCount, result)
-				}
-			} else {
-				log.Printf("发送第 %d 个飞书消息失败 (尝试 %d/%d), 状态码: %d, 响应: %s", msgIndex+1, i+1, providerConfig.RetryCount, resp.StatusCode, string(body))
-			}
+// generateSignature 飞书签名校验: 以 timestamp + "\n" + 密钥 作为 HMAC-SHA256 的密钥对空字符串签名后 Base64 编码
+func (s *Service) generateSignature(secret string, timestamp int64) string {
+	stringToSign := fmt.Sprintf("%d\n%s", timestamp, secret)
+	h := hmac.New(sha256.New, []byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
 
-			if i < providerConfig.RetryCount-1 {
-				time.Sleep(time.Second * time.Duration(i+1))
-			}
-		}
+func classify(resp *http.Response, body []byte) error {
+	if resp.StatusCode != http.StatusOK {
+		return delivery.StatusError(resp, body)
+	}
 
-		if !success {
-			log.Printf("第 %d 个飞书消息发送失败，重试 %d 次后仍然失败", msgIndex+1, providerConfig.RetryCount)
-		}
+	var result struct {
+		Code *int   `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析响应失败: %w, 响应: %s", err, string(body))
+	}
+	// 检查飞书返回的具体业务错误码
+	if result.Code == nil {
+		return fmt.Errorf("响应中没有 code, 响应: %s", string(body))
+	}
+	if *result.Code == 0 {
+		return nil
 	}
 
-	return nil
+	err := &delivery.ProviderError{Provider: "飞书", Code: *result.Code, Message: result.Msg}
+	if *result.Code == rateLimitCode {
+		return delivery.RetryAfter(err, rateLimitBackoff)
+	}
+	return delivery.Permanent(err)
 }
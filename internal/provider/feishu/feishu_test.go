@@ -0,0 +1,111 @@
+package feishu
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"prometheus-webhook/internal/delivery"
+	"prometheus-webhook/internal/provider/providertest"
+)
+
+const threeCards = `[
+	{"msg_type":"interactive","card":{"header":{"title":{"tag":"plain_text","content":"one"}}}},
+	{"msg_type":"interactive","card":{"header":{"title":{"tag":"plain_text","content":"two"}}}},
+	{"msg_type":"interactive","card":{"header":{"title":{"tag":"plain_text","content":"three"}}}}
+]`
+
+func TestSendMessageSkipsDeliveredCardsOnRetry(t *testing.T) {
+	server := providertest.NewServer(t,
+		providertest.Response{Body: `{"code":0}`},
+		providertest.Response{Body: `{"code":19021,"msg":"sign match fail"}`},
+		providertest.Response{Body: `{"code":0}`},
+		providertest.Response{Body: `{"code":0}`},
+	)
+	providerConfig := providertest.Provider(server.URL)
+	providerConfig.ReceiverName = "ops"
+	s := NewService(nil)
+
+	err := s.SendMessage(providerConfig, threeCards)
+	var batchErr *delivery.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("SendMessage() error = %v, want *delivery.BatchError", err)
+	}
+	if batchErr.Failed() != 1 || batchErr.Results[1].Success || batchErr.Results[1].Code != "19021" {
+		t.Errorf("results = %+v, want only card 2 failed with 19021", batchErr.Results)
+	}
+
+	// Alertmanager 重试同一通知时只重新发送失败的卡片
+	if err := s.SendMessage(providerConfig, threeCards); err != nil {
+		t.Fatalf("SendMessage() retry error = %v", err)
+	}
+	requests := server.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("requests = %d, want 4", len(requests))
+	}
+	if !strings.Contains(requests[3].Body, `"two"`) {
+		t.Errorf("retry sent %s, want card two", requests[3].Body)
+	}
+}
+
+func TestGenerateSignature(t *testing.T) {
+	// 以 timestamp + "\n" + 密钥 作为 HMAC-SHA256 的密钥对空字符串签名
+	got := NewService(nil).generateSignature("secret", 1599360473)
+	if want := "q4jswNiMy51J5JuQV566yJat0/lQ/c+22kINzUgKsGU="; got != want {
+		t.Errorf("generateSignature() = %q, want %q", got, want)
+	}
+}
+
+func TestSendMessageSignsEveryAttempt(t *testing.T) {
+	server := providertest.NewServer(t,
+		providertest.Response{Status: http.StatusInternalServerError},
+		providertest.Response{Body: `{"code":0}`},
+	)
+	providerConfig := providertest.Provider(server.URL)
+	providerConfig.ReceiverName = "ops"
+	providerConfig.Secret = "secret"
+	// 两次尝试间隔超过一秒，重试时的时间戳和签名必须更新
+	providerConfig.Backoff.InitialInterval = 1100 * time.Millisecond
+
+	if err := NewService(nil).SendMessage(providerConfig, `{"msg_type":"interactive","card":{}}`); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("requests = %d, want 2", len(requests))
+	}
+
+	var timestamps []string
+	for _, r := range requests {
+		var body struct {
+			Timestamp string `json:"timestamp"`
+			Sign      string `json:"sign"`
+			MsgType   string `json:"msg_type"`
+		}
+		if err := json.Unmarshal([]byte(r.Body), &body); err != nil {
+			t.Fatalf("invalid body %s: %v", r.Body, err)
+		}
+		timestamp, err := strconv.ParseInt(body.Timestamp, 10, 64)
+		if err != nil {
+			t.Fatalf("timestamp = %q: %v", body.Timestamp, err)
+		}
+		mac := hmac.New(sha256.New, []byte(body.Timestamp+"\n"+"secret"))
+		if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); body.Sign != want || body.MsgType != "interactive" {
+			t.Errorf("body = %s, want sign %q", r.Body, want)
+		}
+		if d := time.Since(time.Unix(timestamp, 0)); d < 0 || d > time.Minute {
+			t.Errorf("timestamp = %s, want the current time", body.Timestamp)
+		}
+		timestamps = append(timestamps, body.Timestamp)
+	}
+	if timestamps[0] == timestamps[1] {
+		t.Errorf("retry reused timestamp %s, want it re-signed", timestamps[0])
+	}
+}
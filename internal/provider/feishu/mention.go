@@ -0,0 +1,50 @@
+package feishu
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"prometheus-webhook/models"
+)
+
+// MergeMentions 在每张卡片末尾追加 <at id=...></at>，带有 fingerprint 的卡片只 @ 该告警对应的人，
+// 其余卡片 (例如风暴摘要) @ 所有告警对应的人；飞书不支持按手机号 @，mobiles 会被忽略
+func (s *Service) MergeMentions(message string, all models.Mentions, byFingerprint map[string]models.Mentions) (string, error) {
+	var feishuMessages []models.FeishuInteractiveMessage
+	if err := json.Unmarshal([]byte(message), &feishuMessages); err != nil {
+		var singleMsg models.FeishuInteractiveMessage
+		if err := json.Unmarshal([]byte(message), &singleMsg); err != nil {
+			return "", fmt.Errorf("解析模板JSON失败: %w", err)
+		}
+		feishuMessages = []models.FeishuInteractiveMessage{singleMsg}
+	}
+
+	for _, feishuMsg := range feishuMessages {
+		mentions := all
+		if feishuMsg.Fingerprint != "" {
+			mentions = byFingerprint[feishuMsg.Fingerprint]
+		}
+		if content := mentionContent(mentions); content != "" {
+			appendElement(feishuMsg.Card, content, false)
+		}
+	}
+
+	jsonData, err := json.Marshal(feishuMessages)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// mentionContent 返回 lark_md 中 @ 用户的文本，id 可以是 open_id 或 user_id
+func mentionContent(mentions models.Mentions) string {
+	var tags []string
+	if mentions.AtAll {
+		tags = append(tags, "<at id=all></at>")
+	}
+	for _, id := range mentions.UserIDs {
+		tags = append(tags, "<at id="+id+"></at>")
+	}
+	return strings.Join(tags, " ")
+}
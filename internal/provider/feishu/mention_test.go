@@ -0,0 +1,62 @@
+package feishu
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"prometheus-webhook/models"
+)
+
+func TestMergeMentions(t *testing.T) {
+	message := `[
+		{"msg_type":"interactive","fingerprint":"fp1","card":{"elements":[]}},
+		{"msg_type":"interactive","fingerprint":"fp2","card":{"elements":[]}},
+		{"msg_type":"interactive","card":{"schema":"2.0","body":{"elements":[]}}}
+	]`
+	all := models.Mentions{UserIDs: []string{"ou_dba", "ou_web"}, Mobiles: []string{"138"}, AtAll: true}
+	byFingerprint := map[string]models.Mentions{
+		"fp1": {UserIDs: []string{"ou_dba"}},
+		"fp2": {},
+	}
+
+	got, err := NewService(nil).MergeMentions(message, all, byFingerprint)
+	if err != nil {
+		t.Fatalf("MergeMentions() error = %v", err)
+	}
+	var cards []struct {
+		Card interface{} `json:"card"`
+	}
+	if err := json.Unmarshal([]byte(got), &cards); err != nil {
+		t.Fatalf("MergeMentions() = %s: %v", got, err)
+	}
+	if len(cards) != 3 {
+		t.Fatalf("cards = %d, want 3", len(cards))
+	}
+
+	// 带 fingerprint 的卡片只 @ 该告警对应的人
+	if c := cardJSON(t, cards[0].Card); !strings.Contains(c, "<at id=ou_dba></at>") || strings.Contains(c, "ou_web") || strings.Contains(c, "id=all") {
+		t.Errorf("card 1 = %s, want only ou_dba", c)
+	}
+	if c := cardJSON(t, cards[1].Card); strings.Contains(c, "<at") {
+		t.Errorf("card 2 = %s, want no mentions", c)
+	}
+	// 没有 fingerprint 的卡片 (例如风暴摘要) @ 所有人，飞书不支持按手机号 @
+	c := cardJSON(t, cards[2].Card)
+	if !strings.Contains(c, "<at id=all></at> <at id=ou_dba></at> <at id=ou_web></at>") || strings.Contains(c, "138") {
+		t.Errorf("card 3 = %s, want all mentions without mobiles", c)
+	}
+}
+
+// cardJSON 编码卡片时不转义 < 和 >，便于检查 <at> 标签
+func cardJSON(t *testing.T, card interface{}) string {
+	t.Helper()
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(card); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
@@ -0,0 +1,125 @@
+package generic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"prometheus-webhook/internal/delivery"
+	"prometheus-webhook/internal/logging"
+	"prometheus-webhook/models"
+)
+
+type Service struct {
+	client *delivery.Client
+}
+
+func NewService() *Service {
+	return &Service{
+		client: delivery.NewClient(),
+	}
+}
+
+// SendMessage 将模板渲染结果原样作为请求体发送，并按配置的状态码和 JSON 路径判断是否成功
+func (s *Service) SendMessage(providerConfig models.WebhookProvider, message string) error {
+	cfg := providerConfig.Generic
+	body := []byte(strings.TrimSpace(message))
+	if strings.Contains(cfg.ContentType, "json") && !json.Valid(body) {
+		return fmt.Errorf("模板渲染结果不是合法的JSON")
+	}
+
+	err := s.client.Send(delivery.NewPolicy(providerConfig), delivery.Request{
+		Name: "通用webhook消息",
+		Build: func(ctx context.Context) (*http.Request, error) {
+			return newRequest(ctx, providerConfig, body)
+		},
+		Classify: func(resp *http.Response, respBody []byte) error {
+			return classify(cfg, resp, respBody)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("通用webhook消息发送成功", "receiver", providerConfig.ReceiverName, logging.KeyURL, providerConfig.WebhookURL)
+	return nil
+}
+
+func newRequest(ctx context.Context, providerConfig models.WebhookProvider, body []byte) (*http.Request, error) {
+	cfg := providerConfig.Generic
+	req, err := http.NewRequestWithContext(ctx, cfg.Method, providerConfig.WebhookURL, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", cfg.ContentType)
+	for key, value := range cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	if cfg.BasicAuth.Username != "" {
+		req.SetBasicAuth(cfg.BasicAuth.Username, cfg.BasicAuth.Password)
+	}
+	if cfg.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.BearerToken)
+	}
+	return req, nil
+}
+
+// classify 状态码不在成功列表中时按 delivery.StatusError 判断是否重试；
+// 响应 JSON 中的值不符合预期时视为接收方暂时未处理成功，可以重试
+func classify(cfg models.GenericConfig, resp *http.Response, respBody []byte) error {
+	if !isSuccessStatus(cfg.SuccessStatusCodes, resp.StatusCode) {
+		return delivery.StatusError(resp, respBody)
+	}
+
+	if cfg.SuccessJSONPath != "" {
+		var result interface{}
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			return fmt.Errorf("解析响应失败: %w, 响应: %s", err, string(respBody))
+		}
+		value, ok := lookupJSONPath(result, cfg.SuccessJSONPath)
+		if !ok || fmt.Sprint(value) != cfg.SuccessJSONValue {
+			return fmt.Errorf("响应中 %s 的值不是 %s, 响应: %s", cfg.SuccessJSONPath, cfg.SuccessJSONValue, string(respBody))
+		}
+	}
+	return nil
+}
+
+func isSuccessStatus(codes []int, statusCode int) bool {
+	if len(codes) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+	for _, code := range codes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupJSONPath 按点分隔的路径查找 JSON 中的值，数字段用于数组下标，例如 data.items.0.status
+func lookupJSONPath(value interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, false
+			}
+			value = v[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
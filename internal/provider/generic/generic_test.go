@@ -0,0 +1,152 @@
+package generic
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"prometheus-webhook/internal/provider/providertest"
+	"prometheus-webhook/models"
+)
+
+func testProvider(url string) models.WebhookProvider {
+	provider := providertest.Provider(url)
+	provider.Generic = models.GenericConfig{Method: "POST", ContentType: "application/json"}
+	return provider
+}
+
+func TestSendMessageRequest(t *testing.T) {
+	server := providertest.NewServer(t, providertest.Response{Status: http.StatusNoContent})
+
+	cfg := testProvider(server.URL)
+	cfg.Generic.BearerToken = "secret-token"
+	cfg.Generic.Headers = map[string]string{"X-Alert-Status": "firing"}
+	if err := NewService().SendMessage(cfg, "  {\"status\":\"firing\"}\n"); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("requests = %d, want 1", len(requests))
+	}
+	req := requests[0]
+	if req.Method != "POST" {
+		t.Errorf("method = %q", req.Method)
+	}
+	if req.Body != `{"status":"firing"}` {
+		t.Errorf("body = %q", req.Body)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer secret-token" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := req.Header.Get("X-Alert-Status"); got != "firing" {
+		t.Errorf("X-Alert-Status = %q", got)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
+
+func TestSendMessageInvalidJSON(t *testing.T) {
+	server := providertest.NewServer(t)
+
+	if err := NewService().SendMessage(testProvider(server.URL), "{not json"); err == nil {
+		t.Fatal("SendMessage() error = nil, want error for invalid JSON")
+	}
+	if n := len(server.Requests()); n != 0 {
+		t.Errorf("requests = %d, want 0", n)
+	}
+}
+
+func TestSendMessageRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		responses    []providertest.Response
+		statusCodes  []int
+		jsonPath     string
+		wantErr      bool
+		wantRequests int
+	}{
+		{
+			name:         "5xx 后重试成功",
+			responses:    []providertest.Response{{Status: http.StatusBadGateway}, {Status: http.StatusOK}},
+			wantRequests: 2,
+		},
+		{
+			name:         "4xx 不重试",
+			responses:    []providertest.Response{{Status: http.StatusBadRequest}},
+			wantErr:      true,
+			wantRequests: 1,
+		},
+		{
+			name:         "重试次数用尽",
+			responses:    []providertest.Response{{Status: http.StatusInternalServerError}},
+			wantErr:      true,
+			wantRequests: 3,
+		},
+		{
+			name:         "状态码不在 success_status_codes 中",
+			responses:    []providertest.Response{{Status: http.StatusCreated}},
+			statusCodes:  []int{http.StatusOK},
+			wantErr:      true,
+			wantRequests: 1,
+		},
+		{
+			name:         "响应 JSON 不符合预期时重试",
+			responses:    []providertest.Response{{Body: `{"data":{"result":"pending"}}`}, {Body: `{"data":{"result":"ok"}}`}},
+			jsonPath:     "data.result",
+			wantRequests: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := providertest.NewServer(t, tt.responses...)
+
+			cfg := testProvider(server.URL)
+			cfg.Generic.SuccessStatusCodes = tt.statusCodes
+			if tt.jsonPath != "" {
+				cfg.Generic.SuccessJSONPath = tt.jsonPath
+				cfg.Generic.SuccessJSONValue = "ok"
+			}
+			err := NewService().SendMessage(cfg, `{}`)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SendMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if n := len(server.Requests()); n != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestLookupJSONPath(t *testing.T) {
+	var doc interface{} = map[string]interface{}{
+		"code": float64(0),
+		"data": map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{"status": "ok"}},
+		},
+	}
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{path: "code", want: "0", wantOK: true},
+		{path: "data.items.0.status", want: "ok", wantOK: true},
+		{path: "data.items.1.status"},
+		{path: "data.missing"},
+		{path: "code.value"},
+	}
+	for _, tt := range tests {
+		value, ok := lookupJSONPath(doc, tt.path)
+		if ok != tt.wantOK {
+			t.Errorf("lookupJSONPath(%q) ok = %v, want %v", tt.path, ok, tt.wantOK)
+			continue
+		}
+		if ok {
+			if got := fmt.Sprint(value); got != tt.want {
+				t.Errorf("lookupJSONPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		}
+	}
+}
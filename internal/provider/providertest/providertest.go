@@ -0,0 +1,89 @@
+// Package providertest 提供各通知渠道测试共用的桩 HTTP 服务器和接收器配置
+package providertest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"prometheus-webhook/models"
+)
+
+// Request 桩服务器收到的一次请求
+type Request struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   string
+}
+
+// Response 桩服务器对一次请求的应答，Status 为 0 时按 200 处理
+type Response struct {
+	Status int
+	Header map[string]string
+	Body   string
+}
+
+// Server 记录收到的请求，并按顺序返回预设的应答，应答用完后重复最后一个
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	requests  []Request
+	responses []Response
+}
+
+// NewServer 启动桩服务器，测试结束时自动关闭
+func NewServer(t testing.TB, responses ...Response) *Server {
+	s := &Server{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Header: r.Header.Clone(),
+		Body:   string(body),
+	})
+	resp := Response{}
+	if n := len(s.responses); n > 0 {
+		resp = s.responses[min(len(s.requests), n)-1]
+	}
+	s.mu.Unlock()
+
+	for k, v := range resp.Header {
+		w.Header().Set(k, v)
+	}
+	if resp.Status != 0 {
+		w.WriteHeader(resp.Status)
+	}
+	io.WriteString(w, resp.Body)
+}
+
+// Requests 返回目前收到的全部请求
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Provider 返回指向 url 的接收器配置，重试间隔缩短到毫秒级以免拖慢测试
+func Provider(url string) models.WebhookProvider {
+	return models.WebhookProvider{
+		WebhookURL: url,
+		Timeout:    time.Second,
+		RetryCount: 3,
+		Backoff:    models.BackoffConfig{InitialInterval: time.Millisecond, Multiplier: 1},
+	}
+}
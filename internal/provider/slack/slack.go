@@ -0,0 +1,66 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"prometheus-webhook/internal/delivery"
+	"prometheus-webhook/internal/logging"
+	"prometheus-webhook/models"
+)
+
+type Service struct {
+	client *delivery.Client
+}
+
+func NewService() *Service {
+	return &Service{
+		client: delivery.NewClient(),
+	}
+}
+
+// SendMessage 将模板渲染出的 Block Kit 负载发送到 Slack incoming webhook
+// Slack 成功时返回 200 和纯文本 "ok"，失败时返回 invalid_payload、no_service 等错误文本
+func (s *Service) SendMessage(providerConfig models.WebhookProvider, message string) error {
+	var slackMsg map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &slackMsg); err != nil {
+		return fmt.Errorf("解析模板JSON失败: %w", err)
+	}
+
+	jsonData, err := json.Marshal(slackMsg)
+	if err != nil {
+		return err
+	}
+
+	err = s.client.Send(delivery.NewPolicy(providerConfig), delivery.Request{
+		Name: "Slack消息",
+		Build: func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", providerConfig.WebhookURL, bytes.NewBuffer(jsonData))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			return req, nil
+		},
+		Classify: classify,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Slack消息发送成功", "receiver", providerConfig.ReceiverName, logging.KeyURL, providerConfig.WebhookURL)
+	return nil
+}
+
+// classify 429 和 5xx 可以重试，其余 4xx (invalid_payload, no_service, channel_not_found 等) 重试也不会成功
+func classify(resp *http.Response, body []byte) error {
+	if resp.StatusCode == http.StatusOK && strings.TrimSpace(string(body)) == "ok" {
+		return nil
+	}
+	return delivery.StatusError(resp, body)
+}
@@ -0,0 +1,82 @@
+package slack
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"prometheus-webhook/internal/provider/providertest"
+)
+
+func TestSendMessage(t *testing.T) {
+	server := providertest.NewServer(t, providertest.Response{Body: "ok"})
+
+	message := `{"text":"告警", "blocks":[{"type":"section"}]}`
+	if err := NewService().SendMessage(providertest.Provider(server.URL), message); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("requests = %d, want 1", len(requests))
+	}
+	if got := requests[0].Body; got != `{"blocks":[{"type":"section"}],"text":"告警"}` {
+		t.Errorf("body = %s", got)
+	}
+	if got := requests[0].Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
+
+func TestSendMessageInvalidJSON(t *testing.T) {
+	server := providertest.NewServer(t, providertest.Response{Body: "ok"})
+
+	if err := NewService().SendMessage(providertest.Provider(server.URL), `{"text":"告警`); err == nil {
+		t.Fatal("SendMessage() error = nil, want error for invalid JSON")
+	}
+	if n := len(server.Requests()); n != 0 {
+		t.Errorf("requests = %d, want 0", n)
+	}
+}
+
+func TestSendMessageErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		response     providertest.Response
+		wantRequests int
+	}{
+		{name: "invalid_payload 不重试", response: providertest.Response{Status: http.StatusBadRequest, Body: "invalid_payload"}, wantRequests: 1},
+		{name: "no_service 不重试", response: providertest.Response{Status: http.StatusNotFound, Body: "no_service"}, wantRequests: 1},
+		{name: "5xx 重试", response: providertest.Response{Status: http.StatusInternalServerError, Body: "error"}, wantRequests: 3},
+		{name: "200 但响应不是 ok 时不重试", response: providertest.Response{Body: "invalid_token"}, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := providertest.NewServer(t, tt.response)
+
+			if err := NewService().SendMessage(providertest.Provider(server.URL), `{"text":"告警"}`); err == nil {
+				t.Fatal("SendMessage() error = nil, want error")
+			}
+			if n := len(server.Requests()); n != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestSendMessageRateLimited(t *testing.T) {
+	server := providertest.NewServer(t,
+		providertest.Response{Status: http.StatusTooManyRequests, Header: map[string]string{"Retry-After": "1"}},
+		providertest.Response{Body: "ok"},
+	)
+
+	start := time.Now()
+	if err := NewService().SendMessage(providertest.Provider(server.URL), `{"text":"告警"}`); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if n := len(server.Requests()); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least Retry-After (1s)", elapsed)
+	}
+}
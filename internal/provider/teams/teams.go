@@ -0,0 +1,80 @@
+package teams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"prometheus-webhook/internal/delivery"
+	"prometheus-webhook/internal/logging"
+	"prometheus-webhook/models"
+)
+
+const adaptiveCardContentType = "application/vnd.microsoft.card.adaptive"
+
+type Service struct {
+	client *delivery.Client
+}
+
+func NewService() *Service {
+	return &Service{
+		client: delivery.NewClient(),
+	}
+}
+
+// SendMessage 将模板渲染出的 Adaptive Card 包装为 Teams 消息后发送到 Workflows webhook
+func (s *Service) SendMessage(providerConfig models.WebhookProvider, message string) error {
+	var card map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &card); err != nil {
+		return fmt.Errorf("解析模板JSON失败: %w", err)
+	}
+
+	jsonData, err := json.Marshal(models.TeamsMessage{
+		Type: "message",
+		Attachments: []models.TeamsAttachment{
+			{ContentType: adaptiveCardContentType, Content: card},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.client.Send(delivery.NewPolicy(providerConfig), delivery.Request{
+		Name: "Teams消息",
+		Build: func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", providerConfig.WebhookURL, bytes.NewBuffer(jsonData))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			return req, nil
+		},
+		Classify: classify,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Teams消息发送成功", "receiver", providerConfig.ReceiverName, logging.KeyURL, providerConfig.WebhookURL)
+	return nil
+}
+
+// legacyThrottled 旧版连接器被限流时在 200 响应体中返回的提示，后面跟着 ContextId 等信息
+const legacyThrottled = "Microsoft Teams endpoint returned HTTP error 429"
+
+// classify 200/202 表示成功；429 按 Retry-After 等待，408 和 5xx 可以重试；
+// 400 卡片格式错误、401/403 无权限、404 工作流不存在、413 消息过大，重试也不会成功
+func classify(resp *http.Response, body []byte) error {
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusAccepted {
+		// 旧版 Office 365 连接器在限流时仍返回 200，只根据它的限流提示判断，避免误判响应体中恰好出现的 429
+		if text := strings.TrimSpace(string(body)); strings.HasPrefix(text, legacyThrottled) {
+			return fmt.Errorf("Teams 限流, 响应: %s", text)
+		}
+		return nil
+	}
+	return delivery.StatusError(resp, body)
+}
@@ -0,0 +1,66 @@
+package teams
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"prometheus-webhook/internal/provider/providertest"
+	"prometheus-webhook/models"
+)
+
+func TestSendMessageWrapsAdaptiveCard(t *testing.T) {
+	server := providertest.NewServer(t, providertest.Response{Status: http.StatusAccepted})
+
+	card := `{"type":"AdaptiveCard","version":"1.4","body":[]}`
+	if err := NewService().SendMessage(providertest.Provider(server.URL), card); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("requests = %d, want 1", len(requests))
+	}
+	var got models.TeamsMessage
+	if err := json.Unmarshal([]byte(requests[0].Body), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Type != "message" || len(got.Attachments) != 1 {
+		t.Fatalf("message = %+v", got)
+	}
+	attachment := got.Attachments[0]
+	if attachment.ContentType != adaptiveCardContentType {
+		t.Errorf("contentType = %q", attachment.ContentType)
+	}
+	if content, _ := attachment.Content.(map[string]interface{}); content["type"] != "AdaptiveCard" {
+		t.Errorf("content = %v, want the rendered card", attachment.Content)
+	}
+}
+
+func TestSendMessageErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		response     providertest.Response
+		wantErr      bool
+		wantRequests int
+	}{
+		{name: "卡片格式错误不重试", response: providertest.Response{Status: http.StatusBadRequest}, wantErr: true, wantRequests: 1},
+		{name: "工作流不存在不重试", response: providertest.Response{Status: http.StatusNotFound}, wantErr: true, wantRequests: 1},
+		{name: "5xx 重试", response: providertest.Response{Status: http.StatusBadGateway}, wantErr: true, wantRequests: 3},
+		{name: "旧版连接器在 200 中返回限流", response: providertest.Response{Body: "Microsoft Teams endpoint returned HTTP error 429 with ContextId tcid=0,server=msgapi-production-eus-azsc2-4-170,cv=2d8b1c.0"}, wantErr: true, wantRequests: 3},
+		{name: "响应体中其他位置出现 429 不是限流", response: providertest.Response{Body: "queued message 4290"}, wantRequests: 1},
+		{name: "200 成功", response: providertest.Response{Body: "1"}, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := providertest.NewServer(t, tt.response)
+
+			err := NewService().SendMessage(providertest.Provider(server.URL), `{"type":"AdaptiveCard"}`)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SendMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if n := len(server.Requests()); n != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", n, tt.wantRequests)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"prometheus-webhook/internal/delivery"
+	"prometheus-webhook/models"
+)
+
+type Service struct {
+	client *delivery.Client
+}
+
+func NewService() *Service {
+	return &Service{
+		client: delivery.NewClient(),
+	}
+}
+
+// SendMessage 使用 sendMessage 将模板渲染出的文本发送到指定的 chat 和话题
+func (s *Service) SendMessage(providerConfig models.WebhookProvider, message string) error {
+	cfg := providerConfig.Telegram
+	jsonData, err := json.Marshal(models.TelegramSendMessageRequest{
+		ChatID:                cfg.ChatID,
+		MessageThreadID:       cfg.MessageThreadID,
+		Text:                  strings.TrimSpace(message),
+		ParseMode:             cfg.ParseMode,
+		DisableWebPagePreview: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	apiURL := strings.TrimRight(cfg.APIBaseURL, "/") + "/bot" + cfg.BotToken + "/sendMessage"
+
+	err = s.client.Send(delivery.NewPolicy(providerConfig), delivery.Request{
+		Name: "Telegram消息",
+		Build: func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			return req, nil
+		},
+		Classify: classify,
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Telegram消息发送成功", "receiver", providerConfig.ReceiverName, "chat_id", cfg.ChatID)
+	return nil
+}
+
+// classify 被限流时按照 Telegram 返回的 retry_after 等待，5xx 可以重试；
+// 400 (无法解析实体、chat 不存在)、401 token 无效、403 被移出群组，重试也不会成功
+func classify(resp *http.Response, body []byte) error {
+	var result models.TelegramResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		err = fmt.Errorf("解析响应失败, 状态码: %d, 响应: %s", resp.StatusCode, string(body))
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return err
+		}
+		return delivery.Permanent(err)
+	}
+	if result.OK {
+		return nil
+	}
+
+	err := &delivery.ProviderError{Provider: "Telegram", Code: result.ErrorCode, Message: result.Description}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || result.ErrorCode == http.StatusTooManyRequests:
+		return delivery.RetryAfter(err, time.Duration(result.Parameters.RetryAfter)*time.Second)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return err
+	default:
+		return delivery.Permanent(err)
+	}
+}
@@ -0,0 +1,105 @@
+package telegram
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"prometheus-webhook/internal/provider/providertest"
+	"prometheus-webhook/models"
+)
+
+func testProvider(apiBaseURL string) models.WebhookProvider {
+	provider := providertest.Provider("")
+	provider.Telegram = models.TelegramConfig{
+		BotToken:        "123:abc",
+		ChatID:          "-100123",
+		MessageThreadID: 42,
+		ParseMode:       "HTML",
+		APIBaseURL:      apiBaseURL,
+	}
+	return provider
+}
+
+func TestSendMessageRequest(t *testing.T) {
+	server := providertest.NewServer(t, providertest.Response{Body: `{"ok":true}`})
+
+	if err := NewService().SendMessage(testProvider(server.URL+"/"), "\n<b>告警</b>\n"); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("requests = %d, want 1", len(requests))
+	}
+	if requests[0].Path != "/bot123:abc/sendMessage" {
+		t.Errorf("path = %q", requests[0].Path)
+	}
+	var got models.TelegramSendMessageRequest
+	if err := json.Unmarshal([]byte(requests[0].Body), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := models.TelegramSendMessageRequest{
+		ChatID:                "-100123",
+		MessageThreadID:       42,
+		Text:                  "<b>告警</b>",
+		ParseMode:             "HTML",
+		DisableWebPagePreview: true,
+	}
+	if got != want {
+		t.Errorf("request = %+v, want %+v", got, want)
+	}
+}
+
+func TestSendMessageRetryAfter(t *testing.T) {
+	server := providertest.NewServer(t,
+		providertest.Response{
+			Status: http.StatusTooManyRequests,
+			Body:   `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`,
+		},
+		providertest.Response{Body: `{"ok":true}`},
+	)
+
+	start := time.Now()
+	if err := NewService().SendMessage(testProvider(server.URL), "告警"); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if n := len(server.Requests()); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least retry_after (1s)", elapsed)
+	}
+}
+
+func TestSendMessageRetryAfterTooLong(t *testing.T) {
+	server := providertest.NewServer(t, providertest.Response{
+		Status: http.StatusTooManyRequests,
+		Body:   `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 3600","parameters":{"retry_after":3600}}`,
+	})
+
+	start := time.Now()
+	if err := NewService().SendMessage(testProvider(server.URL), "告警"); err == nil {
+		t.Fatal("SendMessage() error = nil, want error when retry_after exceeds the wait budget")
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("SendMessage() took %s, want it to give up without waiting", elapsed)
+	}
+}
+
+func TestSendMessagePermanentError(t *testing.T) {
+	server := providertest.NewServer(t, providertest.Response{
+		Status: http.StatusForbidden,
+		Body:   `{"ok":false,"error_code":403,"description":"Forbidden: bot was kicked from the group chat"}`,
+	})
+
+	if err := NewService().SendMessage(testProvider(server.URL), "告警"); err == nil {
+		t.Fatal("SendMessage() error = nil, want error")
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
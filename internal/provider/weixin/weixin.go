@@ -5,20 +5,32 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"prometheus-webhook/internal/delivery"
+	"prometheus-webhook/internal/logging"
 	"prometheus-webhook/models"
+	"strings"
 	"time"
 )
 
+// retryableCodes 可以重试的企业微信错误码: -1 系统繁忙
+// 其余错误码 (key 无效、消息格式错误、内容超长等) 重试也不会成功
+var retryableCodes = map[int]bool{-1: true}
+
+// rateLimitCode 接口调用超过限制，企业微信按分钟统计发送次数，等待下一个统计周期再发送
+const (
+	rateLimitCode    = 45009
+	rateLimitBackoff = time.Minute
+)
+
 type Service struct {
-	httpClient *http.Client
+	client *delivery.Client
 }
 
 func NewService() *Service {
 	return &Service{
-		httpClient: &http.Client{},
+		client: delivery.NewClient(),
 	}
 }
 
@@ -33,53 +45,91 @@ func (s *Service) SendMessage(providerConfig models.WebhookProvider, message str
 		return err
 	}
 
-	for i := 0; i < providerConfig.RetryCount; i++ {
-		ctx, cancel := context.WithTimeout(context.Background(), providerConfig.Timeout)
-		defer cancel()
+	err = s.client.Send(delivery.NewPolicy(providerConfig), delivery.Request{
+		Name: "企业微信消息",
+		Build: func(ctx context.Context) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, "POST", providerConfig.WebhookURL, bytes.NewBuffer(jsonData))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			return req, nil
+		},
+		Classify: classify,
+	})
+	if err != nil {
+		return err
+	}
 
-		req, err := http.NewRequestWithContext(ctx, "POST", providerConfig.WebhookURL, bytes.NewBuffer(jsonData))
-		if err != nil {
-			return fmt.Errorf("创建请求失败: %w", err)
-		}
-		req.Header.Set("Content-Type", "application/json")
+	slog.Info("企业微信消息发送成功", "receiver", providerConfig.ReceiverName, logging.KeyURL, providerConfig.WebhookURL)
+	return nil
+}
 
-		resp, err := s.httpClient.Do(req)
-		if err != nil {
-			log.Printf("发送企业微信消息失败 (尝试 %d/%d): %v", i+1, providerConfig.RetryCount, err)
-			if i < providerConfig.RetryCount-1 {
-				time.Sleep(time.Second * time.Duration(i+1))
-			}
-			continue
-		}
-		defer resp.Body.Close()
+func classify(resp *http.Response, body []byte) error {
+	if resp.StatusCode != http.StatusOK {
+		return delivery.StatusError(resp, body)
+	}
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("读取响应失败 (尝试 %d/%d): %v", i+1, providerConfig.RetryCount, err)
-			continue
-		}
+	var result struct {
+		ErrCode *int   `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析响应失败: %w, 响应: %s", err, string(body))
+	}
+	if result.ErrCode == nil {
+		return fmt.Errorf("响应中没有 errcode, 响应: %s", string(body))
+	}
+	if *result.ErrCode == 0 {
+		return nil
+	}
 
-		if resp.StatusCode == http.StatusOK {
-			var result map[string]interface{}
-			if err := json.Unmarshal(body, &result); err != nil {
-				log.Printf("解析响应失败 (尝试 %d/%d): %v", i+1, providerConfig.RetryCount, err)
-				continue
-			}
+	err := &delivery.ProviderError{Provider: "企业微信", Code: *result.ErrCode, Message: result.ErrMsg}
+	if *result.ErrCode == rateLimitCode {
+		return delivery.RetryAfter(err, rateLimitBackoff)
+	}
+	if retryableCodes[*result.ErrCode] {
+		return err
+	}
+	return delivery.Permanent(err)
+}
 
-			if errcode, ok := result["errcode"].(float64); ok && errcode == 0 {
-				log.Printf("企业微信消息发送成功到: %s", providerConfig.WebhookURL)
-				return nil
-			} else {
-				log.Printf("企业微信API返回错误 (尝试 %d/%d): %v", i+1, providerConfig.RetryCount, result)
-			}
-		} else {
-			log.Printf("发送企业微信消息失败 (尝试 %d/%d), 状态码: %d, 响应: %s", i+1, providerConfig.RetryCount, resp.StatusCode, string(body))
-		}
+// MergeMentions 将需要 @ 的人合并到消息中: text 消息使用 mentioned_list 和 mentioned_mobile_list，
+// markdown 消息只支持在正文中使用 <@userid>，按手机号 @ 和 @所有人 会被忽略
+func (s *Service) MergeMentions(message string, mentions models.Mentions, _ map[string]models.Mentions) (string, error) {
+	var weixinMsg map[string]interface{}
+	if err := json.Unmarshal([]byte(message), &weixinMsg); err != nil {
+		return "", fmt.Errorf("解析模板JSON失败: %w", err)
+	}
 
-		if i < providerConfig.RetryCount-1 {
-			time.Sleep(time.Second * time.Duration(i+1))
+	switch weixinMsg["msgtype"] {
+	case "text":
+		text, ok := weixinMsg["text"].(map[string]interface{})
+		if !ok {
+			break
+		}
+		userIDs := mentions.UserIDs
+		if mentions.AtAll {
+			userIDs = append(append([]string{}, userIDs...), "@all")
+		}
+		models.MergeMentionList(text, "mentioned_list", userIDs)
+		models.MergeMentionList(text, "mentioned_mobile_list", mentions.Mobiles)
+	case "markdown":
+		markdown, ok := weixinMsg["markdown"].(map[string]interface{})
+		if !ok || len(mentions.UserIDs) == 0 {
+			break
 		}
+		var tags []string
+		for _, id := range mentions.UserIDs {
+			tags = append(tags, "<@"+id+">")
+		}
+		content, _ := markdown["content"].(string)
+		markdown["content"] = content + "\n" + strings.Join(tags, " ")
 	}
 
-	return fmt.Errorf("发送企业微信消息失败，重试 %d 次后仍然失败", providerConfig.RetryCount)
+	jsonData, err := json.Marshal(weixinMsg)
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
 }
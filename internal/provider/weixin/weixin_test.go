@@ -0,0 +1,59 @@
+package weixin
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"prometheus-webhook/models"
+)
+
+func TestMergeMentions(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		mentions models.Mentions
+		want     string
+	}{
+		{
+			name:     "text 消息合并 mentioned_list 和 mentioned_mobile_list",
+			message:  `{"msgtype":"text","text":{"content":"磁盘","mentioned_list":["u1"]}}`,
+			mentions: models.Mentions{Mobiles: []string{"138"}, UserIDs: []string{"u1", "u2"}},
+			want:     `{"msgtype":"text","text":{"content":"磁盘","mentioned_list":["u1","u2"],"mentioned_mobile_list":["138"]}}`,
+		},
+		{
+			name:     "text 消息 @所有人",
+			message:  `{"msgtype":"text","text":{"content":"磁盘"}}`,
+			mentions: models.Mentions{Mobiles: []string{"138"}, UserIDs: []string{"u1"}, AtAll: true},
+			want:     `{"msgtype":"text","text":{"content":"磁盘","mentioned_list":["u1","@all"],"mentioned_mobile_list":["138"]}}`,
+		},
+		{
+			name:     "没有手机号时不设置空的 mentioned_mobile_list",
+			message:  `{"msgtype":"text","text":{"content":"磁盘"}}`,
+			mentions: models.Mentions{UserIDs: []string{"u1"}},
+			want:     `{"msgtype":"text","text":{"content":"磁盘","mentioned_list":["u1"]}}`,
+		},
+		{
+			name:     "markdown 消息只支持在正文中 @userid",
+			message:  `{"msgtype":"markdown","markdown":{"content":"磁盘"}}`,
+			mentions: models.Mentions{Mobiles: []string{"138"}, UserIDs: []string{"u1", "u2"}},
+			want:     `{"markdown":{"content":"磁盘\n<@u1> <@u2>"},"msgtype":"markdown"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewService().MergeMentions(tt.message, tt.mentions, nil)
+			if err != nil {
+				t.Fatalf("MergeMentions() error = %v", err)
+			}
+			var gotJSON, wantJSON interface{}
+			if err := json.Unmarshal([]byte(got), &gotJSON); err != nil {
+				t.Fatalf("MergeMentions() = %s: %v", got, err)
+			}
+			json.Unmarshal([]byte(tt.want), &wantJSON)
+			if !reflect.DeepEqual(gotJSON, wantJSON) {
+				t.Errorf("MergeMentions() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package queue
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"prometheus-webhook/internal/metrics"
+	"prometheus-webhook/models"
+)
+
+var (
+	// ErrQueueFull 队列已满且溢出策略为 reject
+	ErrQueueFull = errors.New("发送队列已满")
+	// ErrQueueClosed 队列已关闭，不再接收新消息
+	ErrQueueClosed = errors.New("发送队列已关闭")
+)
+
+// Job 一条已渲染、等待发送的消息
+type Job struct {
+	ID             string // 发件箱中的消息 ID，未启用发件箱时为空
+	Receiver       string
+	GroupKey       string
+	ProviderConfig models.WebhookProvider
+	Message        string
+	EnqueuedAt     time.Time
+}
+
+// Queue 单个接收器的发送队列和工作协程池
+type Queue struct {
+	name     string
+	overflow string
+	jobs     chan Job
+	process  func(Job)
+
+	// OnDrop 在 drop_oldest 策略丢弃消息时调用，需要在提交消息前设置
+	OnDrop func(Job)
+
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// New 创建队列并启动 workers 个协程调用 process 处理消息
+func New(name string, size, workers int, overflow string, process func(Job)) *Queue {
+	q := &Queue{
+		name:     name,
+		overflow: overflow,
+		jobs:     make(chan Job, size),
+		process:  process,
+	}
+	metrics.QueueCapacity.WithLabelValues(name).Set(float64(size))
+	metrics.QueueLength.WithLabelValues(name).Set(0)
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	return q
+}
+
+// Submit 将消息加入队列，队列满时按溢出策略拒绝或丢弃最旧的消息
+func (q *Queue) Submit(job Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	if job.EnqueuedAt.IsZero() {
+		job.EnqueuedAt = time.Now()
+	}
+
+	select {
+	case q.jobs <- job:
+		q.updateLength()
+		return nil
+	default:
+	}
+
+	metrics.QueueOverflows.WithLabelValues(q.name, q.overflow).Inc()
+	if q.overflow != models.QueueOverflowDropOldest {
+		return ErrQueueFull
+	}
+
+	// 丢弃最旧的消息后再次入队；持有锁保证不会有其他 Submit 抢占空位
+	select {
+	case dropped := <-q.jobs:
+		slog.Warn("发送队列已满, 丢弃最旧的消息", "receiver", q.name, "group_key", dropped.GroupKey, "enqueued_at", dropped.EnqueuedAt)
+		if q.OnDrop != nil {
+			q.OnDrop(dropped)
+		}
+	default:
+	}
+	select {
+	case q.jobs <- job:
+		q.updateLength()
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Len 返回当前排队的消息数
+func (q *Queue) Len() int {
+	return len(q.jobs)
+}
+
+// Cap 返回队列深度
+func (q *Queue) Cap() int {
+	return cap(q.jobs)
+}
+
+// Close 停止接收新消息，并等待已入队的消息全部处理完成
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+	q.wg.Wait()
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for job := range q.jobs {
+		q.updateLength()
+		q.process(job)
+	}
+}
+
+func (q *Queue) updateLength() {
+	metrics.QueueLength.WithLabelValues(q.name).Set(float64(len(q.jobs)))
+}
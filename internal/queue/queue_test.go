@@ -0,0 +1,146 @@
+package queue
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"prometheus-webhook/models"
+)
+
+// blockingProcessor 记录处理过的消息，在 release 关闭前阻塞工作协程
+type blockingProcessor struct {
+	started chan string
+	release chan struct{}
+
+	mu        sync.Mutex
+	processed []string
+}
+
+func newBlockingProcessor() *blockingProcessor {
+	return &blockingProcessor{
+		started: make(chan string, 16),
+		release: make(chan struct{}),
+	}
+}
+
+func (p *blockingProcessor) process(job Job) {
+	p.started <- job.Message
+	<-p.release
+	p.mu.Lock()
+	p.processed = append(p.processed, job.Message)
+	p.mu.Unlock()
+}
+
+func (p *blockingProcessor) messages() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.processed...)
+}
+
+// waitStarted 等待工作协程取走一条消息，保证后续提交的消息停留在队列中
+func (p *blockingProcessor) waitStarted(t *testing.T, want string) {
+	t.Helper()
+	select {
+	case got := <-p.started:
+		if got != want {
+			t.Fatalf("worker started %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("worker did not start %q", want)
+	}
+}
+
+func TestSubmitRejectWhenFull(t *testing.T) {
+	p := newBlockingProcessor()
+	q := New("test", 1, 1, models.QueueOverflowReject, p.process)
+
+	if err := q.Submit(Job{Message: "1"}); err != nil {
+		t.Fatal(err)
+	}
+	p.waitStarted(t, "1")
+	if err := q.Submit(Job{Message: "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Submit(Job{Message: "3"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("Submit() error = %v, want ErrQueueFull", err)
+	}
+
+	close(p.release)
+	q.Close()
+	if got := p.messages(); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("processed = %v, want [1 2]", got)
+	}
+}
+
+func TestSubmitDropOldest(t *testing.T) {
+	p := newBlockingProcessor()
+	q := New("test", 2, 1, models.QueueOverflowDropOldest, p.process)
+	var dropped []string
+	q.OnDrop = func(job Job) {
+		dropped = append(dropped, job.Message)
+	}
+
+	for _, m := range []string{"1", "2", "3"} {
+		if err := q.Submit(Job{Message: m}); err != nil {
+			t.Fatal(err)
+		}
+		if m == "1" {
+			p.waitStarted(t, "1")
+		}
+	}
+	if err := q.Submit(Job{Message: "4"}); err != nil {
+		t.Fatalf("Submit() error = %v, want the oldest queued job dropped", err)
+	}
+	if q.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", q.Len())
+	}
+	if !reflect.DeepEqual(dropped, []string{"2"}) {
+		t.Errorf("OnDrop called with %v, want [2]", dropped)
+	}
+
+	close(p.release)
+	q.Close()
+	if got := p.messages(); !reflect.DeepEqual(got, []string{"1", "3", "4"}) {
+		t.Errorf("processed = %v, want [1 3 4]", got)
+	}
+}
+
+func TestCloseDrainsQueuedJobs(t *testing.T) {
+	p := newBlockingProcessor()
+	q := New("test", 3, 1, models.QueueOverflowReject, p.process)
+
+	for _, m := range []string{"1", "2", "3"} {
+		if err := q.Submit(Job{Message: m}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.waitStarted(t, "1")
+
+	closed := make(chan struct{})
+	go func() {
+		q.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("Close() returned before in-flight jobs finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := q.Submit(Job{Message: "4"}); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Submit() after Close error = %v, want ErrQueueClosed", err)
+	}
+
+	close(p.release)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close() did not return after jobs were released")
+	}
+	if got := p.messages(); !reflect.DeepEqual(got, []string{"1", "2", "3"}) {
+		t.Errorf("processed = %v, want [1 2 3]", got)
+	}
+}
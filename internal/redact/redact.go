@@ -0,0 +1,148 @@
+package redact
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// mask 替换敏感内容的占位符
+const mask = "***"
+
+// sensitiveParams 查询参数名中包含这些词时对参数值脱敏，
+// 例如钉钉的 access_token、企业微信的 key、Teams Workflows 的 sig
+var sensitiveParams = []string{"token", "key", "secret", "sig", "sign", "password", "passwd", "auth", "credential"}
+
+// sensitiveHeaders 请求头名中包含这些词时对值脱敏
+var sensitiveHeaders = []string{"authorization", "token", "key", "secret", "signature", "cookie", "password"}
+
+// hostRule 已知平台 webhook 地址中的密钥位置，匹配的地址始终脱敏，不依赖 isOpaque 的判断，
+// 因为这些密钥不一定同时包含字母和数字
+type hostRule struct {
+	host       string   // 主机名，同时匹配其子域名
+	pathPrefix string   // 路径以此开头时最后一段是密钥
+	params     []string // 值为密钥的查询参数
+}
+
+var hostRules = []hostRule{
+	{host: "hooks.slack.com", pathPrefix: "/services/"},
+	{host: "open.feishu.cn", pathPrefix: "/open-apis/bot/v2/hook/"},
+	{host: "open.larksuite.com", pathPrefix: "/open-apis/bot/v2/hook/"},
+	{host: "oapi.dingtalk.com", params: []string{"access_token"}},
+	{host: "qyapi.weixin.qq.com", params: []string{"key"}},
+}
+
+var (
+	// telegramToken Telegram Bot API 路径中的 bot<id>:<token>
+	telegramToken = regexp.MustCompile(`^bot\d+:[A-Za-z0-9_-]+$`)
+	// opaqueSegment 路径中较长的、同时包含字母和数字的段，例如飞书 hook 的 UUID、Slack webhook 的密钥
+	opaqueSegment = regexp.MustCompile(`^[A-Za-z0-9_-]{20,}$`)
+	hasLetter     = regexp.MustCompile(`[A-Za-z]`)
+	hasDigit      = regexp.MustCompile(`[0-9]`)
+)
+
+// URL 对 URL 中的凭据脱敏后返回，用于日志和接口响应
+// 用户名之后的密码、敏感查询参数的值以及路径中形似令牌的段会被替换，无法解析时整体替换
+func URL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return mask
+	}
+
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), mask)
+		}
+	}
+
+	rule := matchHost(u.Hostname())
+	secretPath := rule != nil && rule.pathPrefix != "" && strings.HasPrefix(u.Path, rule.pathPrefix)
+	segments := strings.Split(u.Path, "/")
+	for i, segment := range segments {
+		switch {
+		case secretPath && i == len(segments)-1 && segment != "":
+			segments[i] = keepPrefix(segment)
+		case telegramToken.MatchString(segment):
+			segments[i] = "bot" + mask
+		case isOpaque(segment):
+			segments[i] = keepPrefix(segment)
+		}
+	}
+	u.Path = strings.Join(segments, "/")
+	u.RawPath = ""
+
+	if u.RawQuery != "" {
+		query := u.Query()
+		for name, values := range query {
+			if !containsAny(strings.ToLower(name), sensitiveParams) && (rule == nil || !contains(rule.params, name)) {
+				continue
+			}
+			for i := range values {
+				values[i] = keepPrefix(values[i])
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	// url.URL.String 会对 * 进行转义，这里还原以便阅读
+	return strings.ReplaceAll(u.String(), "%2A", "*")
+}
+
+// Headers 返回对认证相关请求头脱敏后的副本
+func Headers(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	result := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if containsAny(strings.ToLower(name), sensitiveHeaders) {
+			value = mask
+		}
+		result[name] = value
+	}
+	return result
+}
+
+// keepPrefix 保留前 4 个字符便于区分不同的凭据，其余部分替换
+func keepPrefix(value string) string {
+	if len(value) <= 8 {
+		return mask
+	}
+	return value[:4] + mask
+}
+
+// matchHost 返回主机名对应的规则，没有匹配时返回 nil
+func matchHost(host string) *hostRule {
+	host = strings.ToLower(host)
+	for i := range hostRules {
+		if host == hostRules[i].host || strings.HasSuffix(host, "."+hostRules[i].host) {
+			return &hostRules[i]
+		}
+	}
+	return nil
+}
+
+func isOpaque(segment string) bool {
+	return opaqueSegment.MatchString(segment) && hasLetter.MatchString(segment) && hasDigit.MatchString(segment)
+}
+
+func containsAny(s string, words []string) bool {
+	for _, word := range words {
+		if strings.Contains(s, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
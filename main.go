@@ -1,12 +1,27 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
+	"runtime/debug"
+	"syscall"
 
 	"prometheus-webhook/handlers"
+	"prometheus-webhook/internal/logging"
+	"prometheus-webhook/internal/metrics"
+	"prometheus-webhook/internal/outbox"
 	"prometheus-webhook/internal/provider/dingding"
+	"prometheus-webhook/internal/provider/email"
 	"prometheus-webhook/internal/provider/feishu"
+	"prometheus-webhook/internal/provider/generic"
+	"prometheus-webhook/internal/provider/slack"
+	"prometheus-webhook/internal/provider/teams"
+	"prometheus-webhook/internal/provider/telegram"
 	"prometheus-webhook/internal/provider/weixin"
 	"prometheus-webhook/models"
 	"prometheus-webhook/services"
@@ -14,6 +29,13 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// 版本信息，构建时通过 -ldflags "-X main.version=..." 注入
+var (
+	version    = "dev"
+	commitHash = ""
+	buildDate  = ""
+)
+
 func main() {
 	// 加载配置
 	configPath := "config/config.yaml"
@@ -23,20 +45,16 @@ func main() {
 
 	configService := services.NewConfigService()
 	if err := configService.LoadConfig(configPath); err != nil {
-		log.Fatalf("加载配置文件失败: %v", err)
+		fatal("加载配置文件失败", "path", configPath, "error", err)
 	}
 
 	config := configService.GetConfig()
 
-	// 设置时区
-	location, err := services.SetTimezone(config.Template.Timezone)
-	if err != nil {
-		log.Fatalf("加载时区失败: %v", err)
+	// 初始化日志
+	if err := logging.Setup(config.Logging.Level, config.Logging.Format); err != nil {
+		fatal("初始化日志失败", "error", err)
 	}
 
-	// 加载模板服务
-	templateService := services.NewTemplateService(location)
-
 	// 设置Gin模式
 	if config.Logging.Level == "debug" {
 		gin.SetMode(gin.DebugMode)
@@ -48,52 +66,223 @@ func main() {
 	router := gin.New()
 	router.Use(gin.Recovery())
 
-	// 初始化处理器
-	healthHandler := handlers.NewHealthHandler(config)
+	build := buildInfo()
+	metrics.BuildInfo.WithLabelValues(build.Version, build.Commit, build.GoVersion).Set(1)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// 打开持久化发件箱
+	var store *outbox.Store
+	if config.Outbox.Enable {
+		var err error
+		store, err = outbox.Open(config.Outbox.Path)
+		if err != nil {
+			fatal("打开发件箱失败", "path", config.Outbox.Path, "error", err)
+		}
+		slog.Info("发件箱已启用", "path", config.Outbox.Path)
+	}
+
+	// 创建接收器和路由树，配置重新加载时整体替换
+	receivers, err := newReceivers(config, store)
+	if err != nil {
+		fatal("创建接收器失败", "error", err)
+	}
+	if err := receivers.CheckTemplates(); err != nil {
+		slog.Warn("模板检查失败, 使用这些模板的接收器将无法发送消息", "error", err)
+	}
+	metrics.ConfigLastReloadSuccess.SetToCurrentTime()
+	registry := handlers.NewRegistry(receivers)
+	registerWebhookRoutes(router, registry)
+
+	// 配置重新加载: SIGHUP、配置文件变化和 POST /-/reload
+	reloader := newReloader(configPath, store, registry)
+	router.POST("/-/reload", reloader.Handle)
+	go reloader.WatchSignals()
+	if config.Reload.Enabled() {
+		go reloader.WatchFile(config.Reload.Interval)
+	}
+
+	// 模板加载状态
+	router.GET("/api/v1/templates", handlers.NewTemplateHandler(registry).List)
+
+	// 健康检查，/readyz 会检查每个接收器的模板、队列和最近的发送结果
+	healthHandler := handlers.NewHealthHandler(build, registry)
 	router.GET("/health", healthHandler.HealthCheck)
+	router.GET("/livez", healthHandler.Livez)
+	router.GET("/readyz", healthHandler.Readyz)
+
+	// 重新发送上次运行时未发送成功的消息，并注册死信管理接口
+	if store != nil {
+		go replayOutbox(store, receivers.Handlers)
 
-	// 为每个启用的 webhook 创建路由
-	setupWebhookRoutes(router, &config, templateService)
+		deadLetterHandler := handlers.NewDeadLetterHandler(store, registry)
+		router.GET("/api/v1/deadletters", deadLetterHandler.List)
+		router.POST("/api/v1/deadletters/:id/replay", deadLetterHandler.Replay)
+		router.DELETE("/api/v1/deadletters/:id", deadLetterHandler.Delete)
+	}
 
 	// 启动服务器
 	server := handlers.NewServer(config.Server.Port, config.Server.Timeout, router)
 
-	log.Printf("Prometheus Webhook服务启动在端口 %s", config.Server.Port)
-	log.Printf("可用的webhook端点:")
-	if config.Webhooks.Feishu.Enable {
-		log.Printf("  POST http://127.0.0.1:%s/feishu", config.Server.Port)
+	slog.Info("Prometheus Webhook服务启动", "port", config.Server.Port, "version", build.Version, "commit", build.Commit,
+		"receivers", len(config.Receivers), "routes", len(config.Routes))
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal("服务器启动失败", "error", err)
+		}
+	}()
+
+	// 等待退出信号，先停止接收新请求，再等待队列中的消息发送完成
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("正在关闭服务")
+	ctx, cancel := context.WithTimeout(context.Background(), config.Server.Timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("关闭HTTP服务失败", "error", err)
+	}
+	reloader.Close()
+	if store != nil {
+		if err := store.Close(); err != nil {
+			slog.Error("关闭发件箱失败", "error", err)
+		}
+	}
+	slog.Info("服务已关闭")
+}
+
+// buildInfo 返回构建时注入的版本信息，未注入提交信息时尝试从 Go 构建信息中读取
+func buildInfo() handlers.BuildInfo {
+	info := handlers.BuildInfo{
+		Version:   version,
+		Commit:    commitHash,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
 	}
-	if config.Webhooks.Dingding.Enable {
-		log.Printf("  POST http://127.0.0.1:%s/dingding", config.Server.Port)
+	return info
+}
+
+// fatal 记录错误日志后退出
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// newReceivers 根据配置创建所有接收器的处理器和标签路由树
+func newReceivers(config models.Config, store *outbox.Store) (*handlers.Receivers, error) {
+	location, err := services.SetTimezone(config.Template.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("加载时区失败: %w", err)
 	}
-	if config.Webhooks.Weixin.Enable {
-		log.Printf("  POST http://127.0.0.1:%s/weixin", config.Server.Port)
+	// 每次加载配置都使用新的模板服务，修改后的模板文件会被重新解析
+	templateService := services.NewTemplateService(location)
+	if config.Reload.Enabled() {
+		go templateService.Watch(config.Reload.Interval)
 	}
 
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("服务器启动失败: %v", err)
+	receivers := &handlers.Receivers{
+		Config:    config,
+		Handlers:  make(map[string]*handlers.WebhookHandler),
+		Templates: templateService,
+	}
+	if len(config.Routes) > 0 {
+		receivers.Router, err = services.NewAlertRouter(config.Routes)
+		if err != nil {
+			templateService.Close()
+			return nil, fmt.Errorf("创建路由树失败: %w", err)
+		}
 	}
+
+	for _, receiver := range config.Receivers {
+		messageHandler, err := newMessageHandler(receiver.Type, store)
+		if err != nil {
+			receivers.Close()
+			return nil, fmt.Errorf("创建接收器 '%s' 失败: %w", receiver.Name, err)
+		}
+		webhookHandler, err := handlers.NewWebhookHandler(messageHandler, receiver, templateService, store)
+		if err != nil {
+			receivers.Close()
+			return nil, fmt.Errorf("创建接收器 '%s' 失败: %w", receiver.Name, err)
+		}
+		receivers.Handlers[receiver.Name] = webhookHandler
+
+		slog.Info("加载接收器", "receiver", receiver.Name, "type", receiver.Type, "path", "/webhook/"+receiver.Name, logging.KeyURL, receiver.WebhookURL)
+		if receiver.Queue.Enabled() {
+			slog.Info("接收器使用异步队列", "receiver", receiver.Name, "size", receiver.Queue.Size, "workers", receiver.Queue.Workers, "overflow", receiver.Queue.Overflow)
+		}
+	}
+	return receivers, nil
 }
 
-func setupWebhookRoutes(router *gin.Engine, config *models.Config, templateService *services.TemplateService) {
-	if config.Webhooks.Feishu.Enable {
-		feishuService := feishu.NewService()
-		webhookHandler := handlers.NewWebhookHandler(feishuService, config.Webhooks.Feishu, templateService)
-		router.POST("/feishu", webhookHandler.Handle)
-		log.Printf("注册路由: POST /feishu -> %s", config.Webhooks.Feishu.WebhookURL)
+// registerWebhookRoutes 注册接收告警的端点，端点在处理请求时查找当前生效的接收器
+func registerWebhookRoutes(router *gin.Engine, registry *handlers.Registry) {
+	router.POST("/webhook/:name", registry.Handle)
+
+	// 兼容旧版的 /feishu, /dingding, /weixin 端点
+	for _, name := range []string{models.ReceiverTypeFeishu, models.ReceiverTypeDingding, models.ReceiverTypeWeixin} {
+		router.POST("/"+name, registry.HandleLegacy(name))
 	}
 
-	if config.Webhooks.Dingding.Enable {
-		dingdingService := dingding.NewService()
-		webhookHandler := handlers.NewWebhookHandler(dingdingService, config.Webhooks.Dingding, templateService)
-		router.POST("/dingding", webhookHandler.Handle)
-		log.Printf("注册路由: POST /dingding -> %s", config.Webhooks.Dingding.WebhookURL)
+	// 基于标签路由的统一入口
+	router.POST("/alert", handlers.NewAlertHandler(registry).Handle)
+
+	// 飞书应用机器人的卡片按钮回调
+	router.POST("/webhook/:name/callback", handlers.NewFeishuCallbackHandler(registry).Handle)
+}
+
+// replayOutbox 将发件箱中的消息交给对应的接收器重新发送
+func replayOutbox(store *outbox.Store, webhookHandlers map[string]*handlers.WebhookHandler) {
+	entries, err := store.Pending()
+	if err != nil {
+		slog.Error("读取发件箱失败", "error", err)
+		return
+	}
+	if len(entries) == 0 {
+		return
 	}
 
-	if config.Webhooks.Weixin.Enable {
-		weixinService := weixin.NewService()
-		webhookHandler := handlers.NewWebhookHandler(weixinService, config.Webhooks.Weixin, templateService)
-		router.POST("/weixin", webhookHandler.Handle)
-		log.Printf("注册路由: POST /weixin -> %s", config.Webhooks.Weixin.WebhookURL)
+	slog.Info("发件箱中有未发送成功的消息, 开始重新发送", "count", len(entries))
+	for _, entry := range entries {
+		webhookHandler, ok := webhookHandlers[entry.Receiver]
+		if !ok {
+			slog.Warn("发件箱消息的接收器已不存在, 保留该消息", "id", entry.ID, "receiver", entry.Receiver)
+			continue
+		}
+		webhookHandler.Replay(entry)
+	}
+}
+
+// newMessageHandler 根据接收器类型创建对应的消息发送服务
+func newMessageHandler(receiverType string, store *outbox.Store) (handlers.MessageHandler, error) {
+	switch receiverType {
+	case models.ReceiverTypeFeishu:
+		return feishu.NewService(store), nil
+	case models.ReceiverTypeDingding:
+		return dingding.NewService(), nil
+	case models.ReceiverTypeWeixin:
+		return weixin.NewService(), nil
+	case models.ReceiverTypeSlack:
+		return slack.NewService(), nil
+	case models.ReceiverTypeTeams:
+		return teams.NewService(), nil
+	case models.ReceiverTypeTelegram:
+		return telegram.NewService(), nil
+	case models.ReceiverTypeEmail:
+		return email.NewService(), nil
+	case models.ReceiverTypeGeneric:
+		return generic.NewService(), nil
+	default:
+		return nil, fmt.Errorf("不支持的接收器类型: %s", receiverType)
 	}
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"prometheus-webhook/handlers"
+	"prometheus-webhook/internal/outbox"
+	"prometheus-webhook/models"
+	"prometheus-webhook/services"
+)
+
+type recordingSender struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (s *recordingSender) SendMessage(providerConfig models.WebhookProvider, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+	return nil
+}
+
+func TestReplayOutbox(t *testing.T) {
+	store, err := outbox.Open(filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	for _, entry := range []outbox.Entry{
+		{Receiver: "ops", Message: "first"},
+		{Receiver: "removed", Message: "orphan"},
+		{Receiver: "ops", Message: "second"},
+	} {
+		if err := store.Put(&entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sender := &recordingSender{}
+	receiver := models.Receiver{Name: "ops", Type: models.ReceiverTypeGeneric}
+	handler, err := handlers.NewWebhookHandler(sender, receiver, services.NewTemplateService(time.UTC), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayOutbox(store, map[string]*handlers.WebhookHandler{"ops": handler})
+
+	if len(sender.messages) != 2 || sender.messages[0] != "first" || sender.messages[1] != "second" {
+		t.Errorf("replayed = %q, want [first second] in write order", sender.messages)
+	}
+	// 接收器已不存在的消息保留在发件箱中
+	entries, err := store.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Receiver != "removed" {
+		t.Errorf("pending = %+v, want only the orphaned message", entries)
+	}
+}
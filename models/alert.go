@@ -25,3 +25,55 @@ type Alert struct {
 	GeneratorURL string            `json:"generatorURL"`
 	Fingerprint  string            `json:"fingerprint"`
 }
+
+// Silence Alertmanager v2 API 创建静默的请求结构
+type Silence struct {
+	Matchers  []SilenceMatcher `json:"matchers"`
+	StartsAt  time.Time        `json:"startsAt"`
+	EndsAt    time.Time        `json:"endsAt"`
+	CreatedBy string           `json:"createdBy"`
+	Comment   string           `json:"comment"`
+}
+
+// SilenceMatcher 静默的标签匹配条件
+type SilenceMatcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+// Mentions 告警需要 @ 的人
+type Mentions struct {
+	Mobiles []string `json:"mobiles,omitempty"`
+	UserIDs []string `json:"userIds,omitempty"`
+	AtAll   bool     `json:"atAll,omitempty"`
+}
+
+// Empty 返回是否不需要 @ 任何人
+func (m Mentions) Empty() bool {
+	return len(m.Mobiles) == 0 && len(m.UserIDs) == 0 && !m.AtAll
+}
+
+// MergeMentionList 将 values 合并到消息中 fields[key] 已有的列表，去掉重复的值；
+// 合并后列表为空时不设置该字段，避免发送 null
+func MergeMentionList(fields map[string]interface{}, key string, values []string) {
+	var result []string
+	seen := make(map[string]bool)
+	items, _ := fields[key].([]interface{})
+	for _, item := range items {
+		if s, ok := item.(string); ok && !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	for _, s := range values {
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	if len(result) > 0 {
+		fields[key] = result
+	}
+}
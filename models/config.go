@@ -2,6 +2,18 @@ package models
 
 import "time"
 
+// 支持的接收器类型
+const (
+	ReceiverTypeFeishu   = "feishu"
+	ReceiverTypeDingding = "dingding"
+	ReceiverTypeWeixin   = "weixin"
+	ReceiverTypeSlack    = "slack"
+	ReceiverTypeTeams    = "teams"
+	ReceiverTypeTelegram = "telegram"
+	ReceiverTypeEmail    = "email"
+	ReceiverTypeGeneric  = "generic"
+)
+
 // Config 配置文件结构体
 type Config struct {
 	Server struct {
@@ -18,6 +30,31 @@ type Config struct {
 		Timezone string `yaml:"timezone"`
 	} `yaml:"template"`
 
+	// Queue 异步发送队列的全局默认配置，接收器可以单独覆盖
+	Queue QueueConfig `yaml:"queue"`
+
+	// Dedup 重复通知过滤的全局默认配置，接收器可以单独覆盖
+	Dedup DedupConfig `yaml:"dedup"`
+
+	// Outbox 持久化发件箱，消息在发送前落盘，服务重启后重新发送未成功的消息
+	Outbox struct {
+		Enable bool   `yaml:"enable"`
+		Path   string `yaml:"path"`
+	} `yaml:"outbox"`
+
+	// Reload 配置文件变化检测，修改后的配置会在不重启服务的情况下生效
+	Reload ReloadConfig `yaml:"reload"`
+
+	// Readiness /readyz 就绪检查的配置
+	Readiness ReadinessConfig `yaml:"readiness"`
+
+	// Receivers 具名接收器列表，每个接收器对应一个 POST /webhook/{name} 端点
+	Receivers []Receiver `yaml:"receivers"`
+
+	// Routes 基于标签的路由树，用于 POST /alert 端点将告警分发到各个接收器
+	Routes []Route `yaml:"routes"`
+
+	// Webhooks 旧版的固定配置，启用的条目会被转换为同名接收器
 	Webhooks struct {
 		Feishu   WebhookProvider `yaml:"feishu"`
 		Dingding WebhookProvider `yaml:"dingding"`
@@ -25,12 +62,206 @@ type Config struct {
 	} `yaml:"webhooks"`
 }
 
+// Receiver 定义了一个具名的告警接收器
+type Receiver struct {
+	Name            string        `yaml:"name"`
+	Type            string        `yaml:"type"`
+	Queue           QueueConfig   `yaml:"queue,omitempty"`
+	Dedup           DedupConfig   `yaml:"dedup,omitempty"`
+	Storm           StormConfig   `yaml:"storm,omitempty"`
+	Mentions        MentionConfig `yaml:"mentions,omitempty"`
+	WebhookProvider `yaml:",inline"`
+}
+
+// DedupConfig 重复通知过滤配置
+// 同一告警组中的告警 (按 fingerprint 区分) 状态不变时，window 内只发送一次；
+// 设置了 reminder_interval 时，持续触发的告警每隔 reminder_interval 再发送一次提醒
+type DedupConfig struct {
+	Enable           *bool         `yaml:"enable,omitempty"`
+	Window           time.Duration `yaml:"window,omitempty"`
+	ReminderInterval time.Duration `yaml:"reminder_interval,omitempty"`
+}
+
+// Enabled 返回是否启用去重，未配置时视为关闭
+func (d DedupConfig) Enabled() bool {
+	return d.Enable != nil && *d.Enable
+}
+
+// StormConfig 告警风暴保护配置，一次通知中的告警数超过 threshold 时改用汇总模板发送一条摘要
+type StormConfig struct {
+	Threshold int    `yaml:"threshold,omitempty"` // 为 0 时不启用
+	Template  string `yaml:"template,omitempty"`  // 汇总模板，例如 templates/feishu_summary.tmpl
+	TopK      int    `yaml:"top_k,omitempty"`     // 摘要中列出的告警数，默认 10
+}
+
+// MentionConfig 根据告警标签 @ 相关人员，只对触发中的告警生效
+// mobiles 和 user_ids 填写接收器所在平台的标识: 钉钉的手机号和 userId，企业微信的手机号和 userid，飞书的 open_id 或 user_id
+type MentionConfig struct {
+	Rules []MentionRule `yaml:"rules,omitempty"`
+	// AtAllSeverities 告警的 severity 标签在列表中时 @所有人，例如 ["critical"]
+	AtAllSeverities []string `yaml:"at_all_severities,omitempty"`
+}
+
+// MentionRule 告警标签满足所有匹配条件时 @ 对应的人，没有匹配条件时匹配所有告警
+type MentionRule struct {
+	Matchers []string `yaml:"matchers"` // 与 routes 的匹配条件语法相同，例如 team="dba"
+	Mobiles  []string `yaml:"mobiles,omitempty"`
+	UserIDs  []string `yaml:"user_ids,omitempty"`
+}
+
+// 队列满时的处理策略
+const (
+	QueueOverflowReject     = "reject"      // 拒绝新消息，返回 503 让 Alertmanager 稍后重试
+	QueueOverflowDropOldest = "drop_oldest" // 丢弃队列中最旧的消息
+)
+
+// ReloadConfig 配置文件变化检测配置
+type ReloadConfig struct {
+	Enable   *bool         `yaml:"enable,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"` // 检查配置文件是否变化的间隔
+}
+
+// Enabled 返回是否定期检查配置文件变化，未配置时视为开启
+func (r ReloadConfig) Enabled() bool {
+	return r.Enable == nil || *r.Enable
+}
+
+// ReadinessConfig 就绪检查配置
+type ReadinessConfig struct {
+	// QueueThreshold 异步队列中的消息数达到队列深度的该比例时视为未就绪
+	QueueThreshold float64 `yaml:"queue_threshold"`
+	// DeliveryWindow 大于 0 时，接收器在该时间内有发送且全部失败视为未就绪
+	DeliveryWindow time.Duration `yaml:"delivery_window"`
+}
+
+// QueueConfig 异步发送队列配置
+type QueueConfig struct {
+	Enable   *bool  `yaml:"enable,omitempty"`
+	Size     int    `yaml:"size,omitempty"`     // 队列深度
+	Workers  int    `yaml:"workers,omitempty"`  // 每个接收器的发送协程数
+	Overflow string `yaml:"overflow,omitempty"` // reject 或 drop_oldest
+}
+
+// Enabled 返回是否启用异步队列，未配置时视为关闭
+func (q QueueConfig) Enabled() bool {
+	return q.Enable != nil && *q.Enable
+}
+
+// Route 定义了路由树中的一个节点
+// 告警按顺序匹配同级路由，命中后继续匹配子路由；没有子路由命中时使用当前节点的接收器，
+// 没有配置 receiver 的节点继承父节点的接收器。
+// 除非设置了 continue，否则命中第一个路由后不再匹配后续的同级路由；
+// 没有配置 receiver 且没有子路由命中的顶层节点不算命中。
+type Route struct {
+	Receiver string   `yaml:"receiver"`
+	Matchers []string `yaml:"matchers"` // 例如 severity="critical", team=~"db|dba", env!="dev"
+	Continue bool     `yaml:"continue"`
+	Routes   []Route  `yaml:"routes"`
+}
+
 // WebhookProvider 定义了单个 webhook 提供商的配置
 type WebhookProvider struct {
+	// ReceiverName 所属接收器的名称，加载配置时填充，用于日志和指标
+	ReceiverName string `yaml:"-"`
+
 	Enable     bool          `yaml:"enable"`
 	WebhookURL string        `yaml:"webhook_url"`
-	Secret     string        `yaml:"secret,omitempty"` // 用于钉钉签名
+	Secret     string        `yaml:"secret,omitempty"` // 用于钉钉加签和飞书签名校验
 	Timeout    time.Duration `yaml:"timeout"`
-	RetryCount int           `yaml:"retry_count"`
+	RetryCount int           `yaml:"retry_count"` // 最大尝试次数
 	Template   string        `yaml:"template"`
+
+	Backoff   BackoffConfig   `yaml:"backoff,omitempty"`
+	RateLimit RateLimitConfig `yaml:"rate_limit,omitempty"`
+
+	Telegram  TelegramConfig  `yaml:"telegram,omitempty"`
+	Email     EmailConfig     `yaml:"email,omitempty"`
+	Generic   GenericConfig   `yaml:"generic,omitempty"`
+	FeishuApp FeishuAppConfig `yaml:"feishu_app,omitempty"`
+}
+
+// BackoffConfig 重试退避策略，第 n 次重试前等待 initial_interval * multiplier^(n-1)，
+// 不超过 max_interval，并在 ±jitter 比例内随机抖动
+type BackoffConfig struct {
+	InitialInterval time.Duration `yaml:"initial_interval,omitempty"`
+	MaxInterval     time.Duration `yaml:"max_interval,omitempty"`
+	Multiplier      float64       `yaml:"multiplier,omitempty"`
+	Jitter          float64       `yaml:"jitter,omitempty"`           // 0 到 1 之间
+	MaxElapsedTime  time.Duration `yaml:"max_elapsed_time,omitempty"` // 从第一次尝试开始的最长重试时间
+}
+
+// RateLimitConfig 令牌桶限流配置，每 interval 最多发送 limit 条消息，允许突发 burst 条
+// 超出限制的消息排队等待而不是失败
+type RateLimitConfig struct {
+	Enable   *bool         `yaml:"enable,omitempty"`
+	Limit    int           `yaml:"limit,omitempty"`
+	Interval time.Duration `yaml:"interval,omitempty"`
+	Burst    int           `yaml:"burst,omitempty"`
+}
+
+// Enabled 返回是否启用限流
+func (r RateLimitConfig) Enabled() bool {
+	return r.Enable != nil && *r.Enable
+}
+
+// TelegramConfig Telegram Bot API 接收器的配置
+type TelegramConfig struct {
+	BotToken        string `yaml:"bot_token"`
+	ChatID          string `yaml:"chat_id"`
+	MessageThreadID int    `yaml:"message_thread_id,omitempty"` // 论坛群组中的话题 ID
+	ParseMode       string `yaml:"parse_mode"`                  // HTML 或 MarkdownV2
+	APIBaseURL      string `yaml:"api_base_url"`                // 默认为 https://api.telegram.org
+}
+
+// FeishuAppConfig 飞书应用机器人的配置，配置 app_id 后 feishu 接收器通过开放平台 IM API 发送到 chat_id，
+// 不需要 webhook_url，告警恢复时更新原来的卡片而不是发送新卡片
+type FeishuAppConfig struct {
+	AppID      string `yaml:"app_id"`
+	AppSecret  string `yaml:"app_secret"`
+	ChatID     string `yaml:"chat_id"`
+	APIBaseURL string `yaml:"api_base_url"` // 默认为 https://open.feishu.cn
+
+	// 卡片回调 POST /webhook/{name}/callback 的配置，与开发者后台中的设置一致，未配置 verification_token 时不处理回调
+	VerificationToken string `yaml:"verification_token,omitempty"`
+	EncryptKey        string `yaml:"encrypt_key,omitempty"`
+	// AlertmanagerURL 静默按钮创建静默时使用的 Alertmanager 地址，未配置时使用发送卡片时通知中的 externalURL；
+	// 不使用回调中的地址，避免持有 verification token 的人让服务向任意地址发送请求
+	AlertmanagerURL string `yaml:"alertmanager_url,omitempty"`
+}
+
+// Enabled 返回是否使用应用机器人发送
+func (f FeishuAppConfig) Enabled() bool {
+	return f.AppID != ""
+}
+
+// EmailConfig SMTP 邮件接收器的配置
+type EmailConfig struct {
+	SMTPHost           string   `yaml:"smtp_host"`
+	SMTPPort           int      `yaml:"smtp_port"`
+	Username           string   `yaml:"username,omitempty"`
+	Password           string   `yaml:"password,omitempty"`
+	From               string   `yaml:"from"`
+	To                 []string `yaml:"to"`
+	CC                 []string `yaml:"cc,omitempty"`
+	BCC                []string `yaml:"bcc,omitempty"`
+	TLS                string   `yaml:"tls"` // starttls (默认), tls (隐式 TLS), none
+	InsecureSkipVerify bool     `yaml:"insecure_skip_verify,omitempty"`
+}
+
+// GenericConfig 通用 HTTP webhook 接收器的配置，模板渲染结果原样作为请求体发送
+type GenericConfig struct {
+	Method      string            `yaml:"method"`       // 默认 POST
+	ContentType string            `yaml:"content_type"` // 默认 application/json
+	Headers     map[string]string `yaml:"headers"`      // 请求头的值支持模板语法
+	BasicAuth   struct {
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+	} `yaml:"basic_auth,omitempty"`
+	BearerToken string `yaml:"bearer_token,omitempty"`
+
+	// 成功判定: 状态码在 success_status_codes 中 (默认任意 2xx)，
+	// 并且配置了 success_json_path 时，响应 JSON 中该路径的值等于 success_json_value
+	SuccessStatusCodes []int  `yaml:"success_status_codes,omitempty"`
+	SuccessJSONPath    string `yaml:"success_json_path,omitempty"` // 例如 code 或 data.result.0.status
+	SuccessJSONValue   string `yaml:"success_json_value,omitempty"`
 }
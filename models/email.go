@@ -0,0 +1,8 @@
+package models
+
+// EmailMessage 邮件模板渲染结果，由 email_message 模板输出为 JSON
+type EmailMessage struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+}
@@ -10,6 +10,96 @@ type FeishuMessage struct {
 
 // FeishuInteractiveMessage 飞书消息卡片结构
 type FeishuInteractiveMessage struct {
-	MsgType string      `json:"msg_type"`
-	Card    interface{} `json:"card"`
+	// Timestamp 和 Sign 在机器人启用签名校验时由发送服务填充
+	Timestamp string      `json:"timestamp,omitempty"`
+	Sign      string      `json:"sign,omitempty"`
+	MsgType   string      `json:"msg_type"`
+	Card      interface{} `json:"card"`
+
+	// Fingerprint 和 Status 由模板填写，应用机器人用它们在告警恢复时找到并更新原来的卡片，发送前会被移除
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Status      string `json:"status,omitempty"`
+	// ExternalURL 由模板填写为通知中的 Alertmanager 地址，随卡片保存在服务端，用于静默按钮，发送前会被移除
+	ExternalURL string `json:"external_url,omitempty"`
+}
+
+// FeishuTenantTokenRequest 获取 tenant_access_token 的请求结构
+type FeishuTenantTokenRequest struct {
+	AppID     string `json:"app_id"`
+	AppSecret string `json:"app_secret"`
+}
+
+// FeishuTenantTokenResponse 获取 tenant_access_token 的响应结构，expire 为剩余有效秒数
+type FeishuTenantTokenResponse struct {
+	Code              int    `json:"code"`
+	Msg               string `json:"msg"`
+	TenantAccessToken string `json:"tenant_access_token"`
+	Expire            int    `json:"expire"`
+}
+
+// FeishuSendMessageRequest IM API 发送消息的请求结构，content 为卡片 JSON 序列化后的字符串
+type FeishuSendMessageRequest struct {
+	ReceiveID string `json:"receive_id"`
+	MsgType   string `json:"msg_type"`
+	Content   string `json:"content"`
+}
+
+// FeishuUpdateMessageRequest IM API 更新卡片消息的请求结构
+type FeishuUpdateMessageRequest struct {
+	Content string `json:"content"`
+}
+
+// FeishuMessageResponse IM API 发送消息的响应结构
+type FeishuMessageResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		MessageID string `json:"message_id"`
+	} `json:"data"`
+}
+
+// FeishuCardCallback 飞书卡片回调请求，同时兼容旧版卡片回调和 2.0 版本的 card.action.trigger 事件
+type FeishuCardCallback struct {
+	// Encrypt 应用配置了 Encrypt Key 时请求体只包含加密后的内容
+	Encrypt string `json:"encrypt,omitempty"`
+
+	// URL 验证请求
+	Type      string `json:"type,omitempty"`
+	Challenge string `json:"challenge,omitempty"`
+
+	// 旧版卡片回调
+	Token         string           `json:"token,omitempty"`
+	OpenID        string           `json:"open_id,omitempty"`
+	OpenMessageID string           `json:"open_message_id,omitempty"`
+	Action        FeishuCardAction `json:"action"`
+
+	// 2.0 版本的回调
+	Schema string `json:"schema,omitempty"`
+	Header struct {
+		Token     string `json:"token"`
+		EventType string `json:"event_type"`
+	} `json:"header"`
+	Event struct {
+		Operator struct {
+			OpenID string `json:"open_id"`
+		} `json:"operator"`
+		Action  FeishuCardAction `json:"action"`
+		Context struct {
+			OpenMessageID string `json:"open_message_id"`
+			OpenChatID    string `json:"open_chat_id"`
+		} `json:"context"`
+	} `json:"event"`
+}
+
+// FeishuCardAction 卡片中被点击的按钮
+type FeishuCardAction struct {
+	Tag   string            `json:"tag"`
+	Value FeishuActionValue `json:"value"`
+}
+
+// FeishuActionValue 按钮的 value，由模板填写
+type FeishuActionValue struct {
+	Action   string            `json:"action"`             // ack 或 silence
+	Duration string            `json:"duration,omitempty"` // 静默时长，例如 1h
+	Labels   map[string]string `json:"labels,omitempty"`   // 静默匹配的告警标签
 }
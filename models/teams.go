@@ -0,0 +1,14 @@
+package models
+
+// TeamsMessage Teams Workflows webhook 消息结构
+type TeamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []TeamsAttachment `json:"attachments"`
+}
+
+// TeamsAttachment Teams 消息附件，content 为 Adaptive Card
+type TeamsAttachment struct {
+	ContentType string      `json:"contentType"`
+	ContentURL  *string     `json:"contentUrl"`
+	Content     interface{} `json:"content"`
+}
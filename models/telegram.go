@@ -0,0 +1,20 @@
+package models
+
+// TelegramSendMessageRequest Telegram Bot API sendMessage 请求结构
+type TelegramSendMessageRequest struct {
+	ChatID                string `json:"chat_id"`
+	MessageThreadID       int    `json:"message_thread_id,omitempty"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview,omitempty"`
+}
+
+// TelegramResponse Telegram Bot API 通用响应结构
+type TelegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"prometheus-webhook/handlers"
+	"prometheus-webhook/internal/logging"
+	"prometheus-webhook/internal/metrics"
+	"prometheus-webhook/internal/outbox"
+	"prometheus-webhook/models"
+	"prometheus-webhook/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reloader 重新加载配置文件，新配置校验通过后整体替换当前的接收器和路由树，校验失败时继续使用旧配置
+type reloader struct {
+	path     string
+	store    *outbox.Store
+	registry *handlers.Registry
+
+	mu       sync.Mutex
+	checksum [sha256.Size]byte
+	// closing 等待被替换的接收器发送完队列中的消息
+	closing sync.WaitGroup
+}
+
+func newReloader(path string, store *outbox.Store, registry *handlers.Registry) *reloader {
+	r := &reloader{
+		path:     path,
+		store:    store,
+		registry: registry,
+	}
+	r.checksum, _ = fileChecksum(path)
+	return r
+}
+
+// Reload 重新加载配置文件，trigger 记录触发方式
+func (r *reloader) Reload(trigger string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// 无论新配置是否有效都记录文件内容，避免无效的配置被反复加载
+	if checksum, err := fileChecksum(r.path); err == nil {
+		r.checksum = checksum
+	}
+
+	if err := r.reload(); err != nil {
+		metrics.ConfigReloads.WithLabelValues("failure").Inc()
+		slog.Error("重新加载配置失败, 继续使用当前配置", "trigger", trigger, "path", r.path, "error", err)
+		return err
+	}
+	metrics.ConfigReloads.WithLabelValues("success").Inc()
+	metrics.ConfigLastReloadSuccess.SetToCurrentTime()
+	return nil
+}
+
+func (r *reloader) reload() error {
+	configService := services.NewConfigService()
+	if err := configService.LoadConfig(r.path); err != nil {
+		return err
+	}
+	config := configService.GetConfig()
+
+	next, err := newReceivers(config, r.store)
+	if err != nil {
+		return err
+	}
+	if err := next.CheckTemplates(); err != nil {
+		next.Close()
+		return err
+	}
+
+	current := r.registry.Current()
+	warnStaticChanges(current.Config, config)
+	if err := logging.Setup(config.Logging.Level, config.Logging.Format); err != nil {
+		next.Close()
+		return err
+	}
+	for name, handler := range next.Handlers {
+		if old, ok := current.Handlers[name]; ok {
+			handler.InheritDedup(old)
+			handler.InheritCards(old)
+		}
+	}
+
+	old := r.registry.Swap(next)
+	slog.Info("配置已重新加载", "path", r.path, "receivers", len(config.Receivers), "routes", len(config.Routes))
+
+	// 新请求已经交给新的接收器，旧接收器在后台发送完队列中的消息
+	r.closing.Add(1)
+	go func() {
+		defer r.closing.Done()
+		old.Close()
+	}()
+	return nil
+}
+
+// Handle 处理 POST /-/reload
+func (r *reloader) Handle(c *gin.Context) {
+	if err := r.Reload("api"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重新加载配置失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "配置已重新加载"})
+}
+
+// WatchSignals 收到 SIGHUP 时重新加载配置
+func (r *reloader) WatchSignals() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		r.Reload("signal")
+	}
+}
+
+// WatchFile 定期检查配置文件内容，变化时重新加载
+// 使用轮询而不是文件系统事件，Kubernetes 通过替换符号链接更新 ConfigMap 时也能检测到
+func (r *reloader) WatchFile(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		checksum, err := fileChecksum(r.path)
+		if err != nil {
+			slog.Warn("读取配置文件失败", "path", r.path, "error", err)
+			continue
+		}
+
+		r.mu.Lock()
+		changed := checksum != r.checksum
+		r.mu.Unlock()
+		if changed {
+			slog.Info("配置文件发生变化, 重新加载", "path", r.path)
+			r.Reload("file")
+		}
+	}
+}
+
+// Close 等待当前接收器和被替换的接收器发送完队列中的消息
+func (r *reloader) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registry.Current().Close()
+	r.closing.Wait()
+}
+
+// warnStaticChanges 提示修改后需要重启服务才能生效的配置
+func warnStaticChanges(old, next models.Config) {
+	if old.Server != next.Server {
+		slog.Warn("server 配置的修改需要重启服务才能生效")
+	}
+	if old.Outbox != next.Outbox {
+		slog.Warn("outbox 配置的修改需要重启服务才能生效")
+	}
+	if old.Reload.Enabled() != next.Reload.Enabled() || old.Reload.Interval != next.Reload.Interval {
+		slog.Warn("reload 配置的修改需要重启服务才能生效")
+	}
+}
+
+func fileChecksum(path string) ([sha256.Size]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	return sha256.Sum256(data), nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"prometheus-webhook/handlers"
+	"prometheus-webhook/internal/provider/providertest"
+	"prometheus-webhook/models"
+	"prometheus-webhook/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// reloadFixture 在临时目录中准备配置文件和模板，接收器把消息发送到桩服务器
+type reloadFixture struct {
+	dir      string
+	path     string
+	server   *providertest.Server
+	registry *handlers.Registry
+	reloader *reloader
+}
+
+func newReloadFixture(t *testing.T) *reloadFixture {
+	t.Helper()
+	f := &reloadFixture{
+		dir:    t.TempDir(),
+		server: providertest.NewServer(t),
+	}
+	f.path = filepath.Join(f.dir, "config.yaml")
+	template := `{{ define "generic_message" }}{"status":{{ toJSON .status }}}{{ end }}`
+	if err := os.WriteFile(filepath.Join(f.dir, "generic.tmpl"), []byte(template), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f.writeConfig(t, "ops")
+
+	configService := services.NewConfigService()
+	if err := configService.LoadConfig(f.path); err != nil {
+		t.Fatal(err)
+	}
+	receivers, err := newReceivers(configService.GetConfig(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.registry = handlers.NewRegistry(receivers)
+	f.reloader = newReloader(f.path, nil, f.registry)
+	t.Cleanup(f.reloader.Close)
+	return f
+}
+
+// writeConfig 写入包含给定接收器的配置文件，所有接收器都启用去重
+func (f *reloadFixture) writeConfig(t *testing.T, names ...string) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("dedup:\n  enable: true\n  window: 1h\nreceivers:\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "  - name: %s\n    type: generic\n    webhook_url: %s\n    template: %s\n",
+			name, f.server.URL, filepath.Join(f.dir, "generic.tmpl"))
+	}
+	f.writeFile(t, b.String())
+}
+
+func (f *reloadFixture) writeFile(t *testing.T, content string) {
+	t.Helper()
+	if err := os.WriteFile(f.path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func firingWebhook() models.AlertmanagerWebhook {
+	return models.AlertmanagerWebhook{
+		Status:   "firing",
+		GroupKey: "{}:{alertname=\"Disk\"}",
+		Alerts:   []models.Alert{{Status: "firing", Fingerprint: "disk", Labels: map[string]string{"alertname": "Disk"}}},
+	}
+}
+
+func TestReloadInvalidConfigKeepsReceivers(t *testing.T) {
+	f := newReloadFixture(t)
+	current := f.registry.Current()
+
+	f.writeFile(t, "receivers:\n  - name: ops\n    type: pager\n")
+	if err := f.reloader.Reload("test"); err == nil {
+		t.Fatal("Reload() with invalid config succeeded")
+	}
+	if f.registry.Current() != current {
+		t.Error("registry was swapped after a failed reload")
+	}
+	if _, ok := f.registry.Current().Handlers["ops"]; !ok {
+		t.Error("receiver ops is missing after a failed reload")
+	}
+
+	// 通过 POST /-/reload 触发时返回 500
+	router := gin.New()
+	router.POST("/-/reload", f.reloader.Handle)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("POST /-/reload status = %d, want 500", w.Code)
+	}
+}
+
+func TestReloadSwapsReceiversAndKeepsDedup(t *testing.T) {
+	f := newReloadFixture(t)
+	if err := f.registry.Current().Handlers["ops"].Process(firingWebhook()); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	f.writeConfig(t, "ops", "dba")
+	router := gin.New()
+	router.POST("/-/reload", f.reloader.Handle)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /-/reload status = %d, body = %s", w.Code, w.Body)
+	}
+
+	current := f.registry.Current()
+	if _, ok := current.Handlers["dba"]; !ok {
+		t.Fatal("receiver dba was not added by the reload")
+	}
+	// 去重记录沿用到新的接收器，状态没有变化的告警不会再次发送
+	if err := current.Handlers["ops"].Process(firingWebhook()); err == nil {
+		t.Error("Process() after reload sent a duplicate notification")
+	}
+	if n := len(f.server.Requests()); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
+
+func TestReloadOnSIGHUP(t *testing.T) {
+	// 测试自己也订阅 SIGHUP，避免 WatchSignals 注册之前收到信号导致进程退出
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	f := newReloadFixture(t)
+	go f.reloader.WatchSignals()
+	f.writeConfig(t, "ops", "dba")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := f.registry.Current().Handlers["dba"]; ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("configuration was not reloaded on SIGHUP")
+		}
+		if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -3,12 +3,18 @@ package services
 import (
 	"fmt"
 	"os"
+	"prometheus-webhook/internal/logging"
 	"prometheus-webhook/models"
+	"regexp"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// receiverNamePattern 限制接收器名称只能包含可以直接用于 URL 路径的字符
+var receiverNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
 type ConfigService struct {
 	config models.Config
 }
@@ -29,6 +35,9 @@ func (cs *ConfigService) LoadConfig(configPath string) error {
 		return err
 	}
 
+	// 将旧版 webhooks 配置转换为接收器
+	cs.convertLegacyWebhooks()
+
 	// 设置默认值
 	cs.setDefaults()
 
@@ -40,6 +49,29 @@ func (cs *ConfigService) LoadConfig(configPath string) error {
 	return nil
 }
 
+// convertLegacyWebhooks 将启用的 webhooks.feishu/dingding/weixin 追加为同名同类型的接收器
+func (cs *ConfigService) convertLegacyWebhooks() {
+	legacy := []struct {
+		name     string
+		provider models.WebhookProvider
+	}{
+		{models.ReceiverTypeFeishu, cs.config.Webhooks.Feishu},
+		{models.ReceiverTypeDingding, cs.config.Webhooks.Dingding},
+		{models.ReceiverTypeWeixin, cs.config.Webhooks.Weixin},
+	}
+
+	for _, l := range legacy {
+		if !l.provider.Enable {
+			continue
+		}
+		cs.config.Receivers = append(cs.config.Receivers, models.Receiver{
+			Name:            l.name,
+			Type:            l.name,
+			WebhookProvider: l.provider,
+		})
+	}
+}
+
 func (cs *ConfigService) setDefaults() {
 	if cs.config.Server.Port == "" {
 		cs.config.Server.Port = "8080"
@@ -52,14 +84,96 @@ func (cs *ConfigService) setDefaults() {
 	cs.setWebhookProviderDefaults(&cs.config.Webhooks.Dingding)
 	cs.setWebhookProviderDefaults(&cs.config.Webhooks.Weixin)
 
+	if cs.config.Reload.Interval == 0 {
+		cs.config.Reload.Interval = 30 * time.Second
+	}
+	if cs.config.Readiness.QueueThreshold == 0 {
+		cs.config.Readiness.QueueThreshold = 0.9
+	}
+	cs.setQueueDefaults(&cs.config.Queue, models.QueueConfig{})
+	cs.setDedupDefaults(&cs.config.Dedup, models.DedupConfig{})
+
+	for i := range cs.config.Receivers {
+		// receivers 列表中的接收器总是启用的
+		cs.config.Receivers[i].Enable = true
+		cs.config.Receivers[i].ReceiverName = cs.config.Receivers[i].Name
+		cs.setQueueDefaults(&cs.config.Receivers[i].Queue, cs.config.Queue)
+		cs.setDedupDefaults(&cs.config.Receivers[i].Dedup, cs.config.Dedup)
+		cs.setWebhookProviderDefaults(&cs.config.Receivers[i].WebhookProvider)
+		cs.setRateLimitDefaults(&cs.config.Receivers[i].RateLimit, cs.config.Receivers[i].Type)
+		if cs.config.Receivers[i].Storm.TopK == 0 {
+			cs.config.Receivers[i].Storm.TopK = 10
+		}
+		switch cs.config.Receivers[i].Type {
+		case models.ReceiverTypeFeishu:
+			cs.setFeishuAppDefaults(&cs.config.Receivers[i].FeishuApp)
+		case models.ReceiverTypeTelegram:
+			cs.setTelegramDefaults(&cs.config.Receivers[i].Telegram)
+		case models.ReceiverTypeEmail:
+			cs.setEmailDefaults(&cs.config.Receivers[i].Email)
+		case models.ReceiverTypeGeneric:
+			cs.setGenericDefaults(&cs.config.Receivers[i].Generic)
+		}
+	}
+
+	if cs.config.Outbox.Path == "" {
+		cs.config.Outbox.Path = "data/outbox.db"
+	}
+
 	if cs.config.Logging.Level == "" {
 		cs.config.Logging.Level = "info"
 	}
+	if cs.config.Logging.Format == "" {
+		cs.config.Logging.Format = logging.FormatText
+	}
 	if cs.config.Template.Timezone == "" {
 		cs.config.Template.Timezone = "Asia/Shanghai"
 	}
 }
 
+// setQueueDefaults 使用 parent 补全未配置的队列参数，parent 为空时使用内置默认值
+func (cs *ConfigService) setQueueDefaults(queue *models.QueueConfig, parent models.QueueConfig) {
+	if queue.Enable == nil {
+		enable := parent.Enabled()
+		queue.Enable = &enable
+	}
+	if queue.Size == 0 {
+		queue.Size = parent.Size
+	}
+	if queue.Size == 0 {
+		queue.Size = 1000
+	}
+	if queue.Workers == 0 {
+		queue.Workers = parent.Workers
+	}
+	if queue.Workers == 0 {
+		queue.Workers = 2
+	}
+	if queue.Overflow == "" {
+		queue.Overflow = parent.Overflow
+	}
+	if queue.Overflow == "" {
+		queue.Overflow = models.QueueOverflowReject
+	}
+}
+
+// setDedupDefaults 使用 parent 补全未配置的去重参数，parent 为空时使用内置默认值
+func (cs *ConfigService) setDedupDefaults(dedup *models.DedupConfig, parent models.DedupConfig) {
+	if dedup.Enable == nil {
+		enable := parent.Enabled()
+		dedup.Enable = &enable
+	}
+	if dedup.Window == 0 {
+		dedup.Window = parent.Window
+	}
+	if dedup.Window == 0 {
+		dedup.Window = 24 * time.Hour
+	}
+	if dedup.ReminderInterval == 0 {
+		dedup.ReminderInterval = parent.ReminderInterval
+	}
+}
+
 func (cs *ConfigService) setWebhookProviderDefaults(provider *models.WebhookProvider) {
 	if provider.Timeout == 0 {
 		provider.Timeout = 10 * time.Second
@@ -67,23 +181,284 @@ func (cs *ConfigService) setWebhookProviderDefaults(provider *models.WebhookProv
 	if provider.RetryCount == 0 {
 		provider.RetryCount = 3
 	}
+
+	backoff := &provider.Backoff
+	if backoff.InitialInterval == 0 {
+		backoff.InitialInterval = time.Second
+	}
+	if backoff.MaxInterval == 0 {
+		backoff.MaxInterval = 30 * time.Second
+	}
+	if backoff.Multiplier == 0 {
+		backoff.Multiplier = 2
+	}
+	if backoff.Jitter == 0 {
+		backoff.Jitter = 0.2
+	}
+	if backoff.MaxElapsedTime == 0 {
+		backoff.MaxElapsedTime = 2 * time.Minute
+	}
+}
+
+// platformRateLimits 各平台自定义机器人的发送频率限制
+var platformRateLimits = map[string]models.RateLimitConfig{
+	models.ReceiverTypeDingding: {Limit: 20, Interval: time.Minute, Burst: 1},
+	models.ReceiverTypeWeixin:   {Limit: 20, Interval: time.Minute, Burst: 1},
+	models.ReceiverTypeFeishu:   {Limit: 100, Interval: time.Minute, Burst: 5},
+}
+
+// setRateLimitDefaults 有平台频率限制的接收器默认启用限流，其余接收器配置了 limit 时启用
+func (cs *ConfigService) setRateLimitDefaults(rateLimit *models.RateLimitConfig, receiverType string) {
+	platform, hasPlatformLimit := platformRateLimits[receiverType]
+	if rateLimit.Enable == nil {
+		enable := hasPlatformLimit || rateLimit.Limit > 0
+		rateLimit.Enable = &enable
+	}
+	if rateLimit.Limit == 0 {
+		rateLimit.Limit = platform.Limit
+	}
+	if rateLimit.Interval == 0 {
+		rateLimit.Interval = platform.Interval
+	}
+	if rateLimit.Interval == 0 {
+		rateLimit.Interval = time.Minute
+	}
+	if rateLimit.Burst == 0 {
+		rateLimit.Burst = platform.Burst
+	}
+	if rateLimit.Burst == 0 {
+		rateLimit.Burst = 1
+	}
+}
+
+func (cs *ConfigService) setFeishuAppDefaults(feishuApp *models.FeishuAppConfig) {
+	if feishuApp.APIBaseURL == "" {
+		feishuApp.APIBaseURL = "https://open.feishu.cn"
+	}
+}
+
+func (cs *ConfigService) setTelegramDefaults(telegram *models.TelegramConfig) {
+	if telegram.APIBaseURL == "" {
+		telegram.APIBaseURL = "https://api.telegram.org"
+	}
+	if telegram.ParseMode == "" {
+		telegram.ParseMode = "HTML"
+	}
+}
+
+func (cs *ConfigService) setEmailDefaults(email *models.EmailConfig) {
+	if email.TLS == "" {
+		email.TLS = "starttls"
+	}
+	if email.SMTPPort == 0 {
+		switch email.TLS {
+		case "tls":
+			email.SMTPPort = 465
+		case "none":
+			email.SMTPPort = 25
+		default:
+			email.SMTPPort = 587
+		}
+	}
+}
+
+func (cs *ConfigService) setGenericDefaults(generic *models.GenericConfig) {
+	if generic.Method == "" {
+		generic.Method = "POST"
+	}
+	generic.Method = strings.ToUpper(generic.Method)
+	if generic.ContentType == "" {
+		generic.ContentType = "application/json"
+	}
 }
 
 func (cs *ConfigService) validateConfig() error {
-	if cs.config.Webhooks.Feishu.Enable {
-		if err := cs.validateWebhookProvider("feishu", cs.config.Webhooks.Feishu); err != nil {
+	if _, err := logging.ParseLevel(cs.config.Logging.Level); err != nil {
+		return fmt.Errorf("logging.level: %w", err)
+	}
+	switch cs.config.Logging.Format {
+	case logging.FormatJSON, logging.FormatText:
+	default:
+		return fmt.Errorf("logging.format 只能是 json 或 text")
+	}
+
+	if cs.config.Reload.Interval < 0 {
+		return fmt.Errorf("reload.interval 不能小于 0")
+	}
+	if cs.config.Readiness.QueueThreshold < 0 || cs.config.Readiness.QueueThreshold > 1 {
+		return fmt.Errorf("readiness.queue_threshold 必须在 0 到 1 之间")
+	}
+	if cs.config.Readiness.DeliveryWindow < 0 {
+		return fmt.Errorf("readiness.delivery_window 不能小于 0")
+	}
+
+	names := make(map[string]bool)
+	for _, receiver := range cs.config.Receivers {
+		if receiver.Name == "" {
+			return fmt.Errorf("接收器必须配置 name")
+		}
+		if !receiverNamePattern.MatchString(receiver.Name) {
+			return fmt.Errorf("接收器名称 '%s' 只能包含字母、数字、'-' 和 '_'", receiver.Name)
+		}
+		if names[receiver.Name] {
+			return fmt.Errorf("接收器名称 '%s' 重复", receiver.Name)
+		}
+		names[receiver.Name] = true
+
+		switch receiver.Queue.Overflow {
+		case models.QueueOverflowReject, models.QueueOverflowDropOldest:
+		default:
+			return fmt.Errorf("接收器 '%s' 的 queue.overflow 只能是 reject 或 drop_oldest", receiver.Name)
+		}
+		if receiver.RetryCount < 0 {
+			return fmt.Errorf("接收器 '%s' 的 retry_count 不能小于 0", receiver.Name)
+		}
+		if receiver.Queue.Size < 0 || receiver.Queue.Workers < 0 {
+			return fmt.Errorf("接收器 '%s' 的 queue.size 和 queue.workers 必须大于 0", receiver.Name)
+		}
+		if receiver.RateLimit.Enabled() && (receiver.RateLimit.Limit <= 0 || receiver.RateLimit.Interval <= 0 || receiver.RateLimit.Burst <= 0) {
+			return fmt.Errorf("接收器 '%s' 的 rate_limit.limit、interval 和 burst 必须大于 0", receiver.Name)
+		}
+		if receiver.Dedup.Window < 0 || receiver.Dedup.ReminderInterval < 0 {
+			return fmt.Errorf("接收器 '%s' 的 dedup.window 和 dedup.reminder_interval 不能小于 0", receiver.Name)
+		}
+		if receiver.Storm.Threshold < 0 || receiver.Storm.TopK < 0 {
+			return fmt.Errorf("接收器 '%s' 的 storm.threshold 和 storm.top_k 不能小于 0", receiver.Name)
+		}
+		if receiver.Storm.Threshold > 0 && receiver.Storm.Template == "" {
+			return fmt.Errorf("接收器 '%s' 启用了 storm 时必须配置 storm.template", receiver.Name)
+		}
+		if _, err := NewMentionResolver(receiver.Mentions); err != nil {
+			return fmt.Errorf("接收器 '%s' 的 mentions 配置无效: %w", receiver.Name, err)
+		}
+		if receiver.Backoff.Multiplier < 1 {
+			return fmt.Errorf("接收器 '%s' 的 backoff.multiplier 不能小于 1", receiver.Name)
+		}
+		if receiver.Backoff.Jitter < 0 || receiver.Backoff.Jitter > 1 {
+			return fmt.Errorf("接收器 '%s' 的 backoff.jitter 必须在 0 到 1 之间", receiver.Name)
+		}
+
+		if err := cs.validateReceiver(receiver); err != nil {
 			return err
 		}
 	}
-	if cs.config.Webhooks.Dingding.Enable {
-		if err := cs.validateWebhookProvider("dingding", cs.config.Webhooks.Dingding); err != nil {
+
+	return cs.validateRoutes(cs.config.Routes, names)
+}
+
+// validateRoutes 检查路由引用的接收器是否存在以及匹配条件是否合法
+func (cs *ConfigService) validateRoutes(routes []models.Route, receivers map[string]bool) error {
+	for _, route := range routes {
+		if route.Receiver == "" && len(route.Routes) == 0 {
+			return fmt.Errorf("路由 %v 必须配置 receiver 或子路由", route.Matchers)
+		}
+		if route.Receiver != "" && !receivers[route.Receiver] {
+			return fmt.Errorf("路由引用了不存在的接收器 '%s'", route.Receiver)
+		}
+		for _, m := range route.Matchers {
+			if _, err := ParseMatcher(m); err != nil {
+				return err
+			}
+		}
+		if err := cs.validateRoutes(route.Routes, receivers); err != nil {
 			return err
 		}
 	}
-	if cs.config.Webhooks.Weixin.Enable {
-		if err := cs.validateWebhookProvider("weixin", cs.config.Webhooks.Weixin); err != nil {
+	return nil
+}
+
+func (cs *ConfigService) validateReceiver(receiver models.Receiver) error {
+	switch receiver.Type {
+	case models.ReceiverTypeFeishu:
+		if receiver.FeishuApp.Enabled() {
+			return cs.validateFeishuApp(receiver)
+		}
+	case models.ReceiverTypeDingding, models.ReceiverTypeWeixin,
+		models.ReceiverTypeSlack, models.ReceiverTypeTeams:
+	case models.ReceiverTypeTelegram:
+		return cs.validateTelegram(receiver)
+	case models.ReceiverTypeEmail:
+		return cs.validateEmail(receiver)
+	case models.ReceiverTypeGeneric:
+		if err := cs.validateGeneric(receiver); err != nil {
 			return err
 		}
+	case "":
+		return fmt.Errorf("必须为接收器 '%s' 配置 type", receiver.Name)
+	default:
+		return fmt.Errorf("接收器 '%s' 的类型 '%s' 不受支持", receiver.Name, receiver.Type)
+	}
+	return cs.validateWebhookProvider(receiver.Name, receiver.WebhookProvider)
+}
+
+// validateFeishuApp 飞书应用机器人通过 app_id、app_secret 和 chat_id 发送，不需要 webhook_url
+func (cs *ConfigService) validateFeishuApp(receiver models.Receiver) error {
+	if receiver.FeishuApp.AppSecret == "" {
+		return fmt.Errorf("必须为 feishu 接收器 '%s' 配置 feishu_app.app_secret", receiver.Name)
+	}
+	if receiver.FeishuApp.ChatID == "" {
+		return fmt.Errorf("必须为 feishu 接收器 '%s' 配置 feishu_app.chat_id", receiver.Name)
+	}
+	if receiver.Template == "" {
+		return fmt.Errorf("必须为启用的 webhook '%s' 配置 template", receiver.Name)
+	}
+	return nil
+}
+
+// validateTelegram Telegram 接收器通过 bot_token 和 chat_id 发送，不需要 webhook_url
+func (cs *ConfigService) validateTelegram(receiver models.Receiver) error {
+	if receiver.Telegram.BotToken == "" {
+		return fmt.Errorf("必须为 telegram 接收器 '%s' 配置 telegram.bot_token", receiver.Name)
+	}
+	if receiver.Telegram.ChatID == "" {
+		return fmt.Errorf("必须为 telegram 接收器 '%s' 配置 telegram.chat_id", receiver.Name)
+	}
+	switch receiver.Telegram.ParseMode {
+	case "HTML", "MarkdownV2":
+	default:
+		return fmt.Errorf("telegram 接收器 '%s' 的 parse_mode 只能是 HTML 或 MarkdownV2", receiver.Name)
+	}
+	if receiver.Template == "" {
+		return fmt.Errorf("必须为启用的 webhook '%s' 配置 template", receiver.Name)
+	}
+	return nil
+}
+
+// validateEmail 邮件接收器通过 SMTP 发送，不需要 webhook_url
+func (cs *ConfigService) validateEmail(receiver models.Receiver) error {
+	email := receiver.Email
+	if email.SMTPHost == "" {
+		return fmt.Errorf("必须为 email 接收器 '%s' 配置 email.smtp_host", receiver.Name)
+	}
+	if email.From == "" {
+		return fmt.Errorf("必须为 email 接收器 '%s' 配置 email.from", receiver.Name)
+	}
+	if len(email.To)+len(email.CC)+len(email.BCC) == 0 {
+		return fmt.Errorf("必须为 email 接收器 '%s' 配置至少一个收件人", receiver.Name)
+	}
+	switch email.TLS {
+	case "starttls", "tls", "none":
+	default:
+		return fmt.Errorf("email 接收器 '%s' 的 tls 只能是 starttls, tls 或 none", receiver.Name)
+	}
+	if receiver.Template == "" {
+		return fmt.Errorf("必须为启用的 webhook '%s' 配置 template", receiver.Name)
+	}
+	return nil
+}
+
+func (cs *ConfigService) validateGeneric(receiver models.Receiver) error {
+	generic := receiver.Generic
+	if generic.BasicAuth.Username != "" && generic.BearerToken != "" {
+		return fmt.Errorf("generic 接收器 '%s' 不能同时配置 basic_auth 和 bearer_token", receiver.Name)
+	}
+	if generic.SuccessJSONPath != "" && generic.SuccessJSONValue == "" {
+		return fmt.Errorf("generic 接收器 '%s' 配置了 success_json_path 时必须配置 success_json_value", receiver.Name)
+	}
+	for _, code := range generic.SuccessStatusCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("generic 接收器 '%s' 的成功状态码 %d 无效", receiver.Name, code)
+		}
 	}
 	return nil
 }
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"prometheus-webhook/models"
+)
+
+// loadConfig 将 YAML 写入临时文件并通过 LoadConfig 加载
+func loadConfig(t *testing.T, content string) (models.Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cs := NewConfigService()
+	err := cs.LoadConfig(path)
+	return cs.GetConfig(), err
+}
+
+func TestLoadConfigConvertsLegacyWebhooks(t *testing.T) {
+	config, err := loadConfig(t, `
+webhooks:
+  feishu:
+    enable: true
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/feishu-token
+    template: templates/feishu.tmpl
+  dingding:
+    enable: true
+    webhook_url: https://oapi.dingtalk.com/robot/send?access_token=dingding-token
+    secret: SEC123
+    template: templates/dingding.tmpl
+  weixin:
+    enable: true
+    webhook_url: https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=weixin-key
+    template: templates/weixin.tmpl
+    retry_count: 5
+receivers:
+  - name: ops-feishu
+    type: feishu
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/ops-token
+    template: templates/feishu.tmpl
+`)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	want := map[string]struct {
+		typ, url   string
+		retryCount int
+	}{
+		"ops-feishu": {models.ReceiverTypeFeishu, "https://open.feishu.cn/open-apis/bot/v2/hook/ops-token", 3},
+		"feishu":     {models.ReceiverTypeFeishu, "https://open.feishu.cn/open-apis/bot/v2/hook/feishu-token", 3},
+		"dingding":   {models.ReceiverTypeDingding, "https://oapi.dingtalk.com/robot/send?access_token=dingding-token", 3},
+		"weixin":     {models.ReceiverTypeWeixin, "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=weixin-key", 5},
+	}
+	if len(config.Receivers) != len(want) {
+		t.Fatalf("receivers = %d, want %d", len(config.Receivers), len(want))
+	}
+	for _, receiver := range config.Receivers {
+		w, ok := want[receiver.Name]
+		if !ok {
+			t.Errorf("unexpected receiver %q", receiver.Name)
+			continue
+		}
+		if receiver.Type != w.typ || receiver.WebhookURL != w.url || receiver.RetryCount != w.retryCount {
+			t.Errorf("receiver %q = {type: %s, url: %s, retry_count: %d}, want %+v",
+				receiver.Name, receiver.Type, receiver.WebhookURL, receiver.RetryCount, w)
+		}
+		if !receiver.Enable {
+			t.Errorf("receiver %q is not enabled", receiver.Name)
+		}
+		if receiver.Name == "dingding" && receiver.Secret != "SEC123" {
+			t.Errorf("dingding secret = %q, want the legacy secret", receiver.Secret)
+		}
+	}
+}
+
+func TestLoadConfigSkipsDisabledLegacyWebhooks(t *testing.T) {
+	config, err := loadConfig(t, `
+webhooks:
+  feishu:
+    enable: false
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/feishu-token
+    template: templates/feishu.tmpl
+`)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if len(config.Receivers) != 0 {
+		t.Errorf("receivers = %+v, want none", config.Receivers)
+	}
+}
+
+func TestLoadConfigRejectsInvalidReceivers(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			name: "重复的接收器名称",
+			config: `
+receivers:
+  - name: ops
+    type: feishu
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/a
+    template: templates/feishu.tmpl
+  - name: ops
+    type: dingding
+    webhook_url: https://oapi.dingtalk.com/robot/send?access_token=b
+    template: templates/dingding.tmpl
+`,
+			wantErr: "接收器名称 'ops' 重复",
+		},
+		{
+			name: "与旧版配置同名",
+			config: `
+webhooks:
+  feishu:
+    enable: true
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/a
+    template: templates/feishu.tmpl
+receivers:
+  - name: feishu
+    type: feishu
+    webhook_url: https://open.feishu.cn/open-apis/bot/v2/hook/b
+    template: templates/feishu.tmpl
+`,
+			wantErr: "接收器名称 'feishu' 重复",
+		},
+		{
+			name: "未知的接收器类型",
+			config: `
+receivers:
+  - name: ops
+    type: pager
+    webhook_url: https://example.com/hook
+    template: templates/feishu.tmpl
+`,
+			wantErr: "接收器 'ops' 的类型 'pager' 不受支持",
+		},
+		{
+			name: "缺少类型",
+			config: `
+receivers:
+  - name: ops
+    webhook_url: https://example.com/hook
+    template: templates/feishu.tmpl
+`,
+			wantErr: "必须为接收器 'ops' 配置 type",
+		},
+		{
+			name: "缺少名称",
+			config: `
+receivers:
+  - type: feishu
+    webhook_url: https://example.com/hook
+    template: templates/feishu.tmpl
+`,
+			wantErr: "接收器必须配置 name",
+		},
+		{
+			name: "名称包含路径字符",
+			config: `
+receivers:
+  - name: ops/feishu
+    type: feishu
+    webhook_url: https://example.com/hook
+    template: templates/feishu.tmpl
+`,
+			wantErr: "只能包含字母、数字",
+		},
+		{
+			name: "缺少 webhook_url",
+			config: `
+receivers:
+  - name: ops
+    type: feishu
+    template: templates/feishu.tmpl
+`,
+			wantErr: "必须为启用的 webhook 'ops' 配置 webhook_url",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(t, tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadConfigExample(t *testing.T) {
+	cs := NewConfigService()
+	if err := cs.LoadConfig(filepath.Join("..", "config", "config.yaml.example")); err != nil {
+		t.Fatalf("LoadConfig(config.yaml.example) error = %v", err)
+	}
+	if len(cs.GetConfig().Receivers) == 0 {
+		t.Error("config.yaml.example has no receivers")
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+
+	"prometheus-webhook/models"
+)
+
+type mentionRule struct {
+	matchers []*Matcher
+	mobiles  []string
+	userIDs  []string
+}
+
+// MentionResolver 根据告警标签计算需要 @ 的人
+type MentionResolver struct {
+	rules           []mentionRule
+	atAllSeverities map[string]bool
+}
+
+func NewMentionResolver(config models.MentionConfig) (*MentionResolver, error) {
+	r := &MentionResolver{atAllSeverities: make(map[string]bool)}
+	for _, rule := range config.Rules {
+		compiled := mentionRule{mobiles: rule.Mobiles, userIDs: rule.UserIDs}
+		for _, s := range rule.Matchers {
+			m, err := ParseMatcher(s)
+			if err != nil {
+				return nil, err
+			}
+			compiled.matchers = append(compiled.matchers, m)
+		}
+		if len(compiled.mobiles) == 0 && len(compiled.userIDs) == 0 {
+			return nil, fmt.Errorf("提醒规则 %v 必须配置 mobiles 或 user_ids", rule.Matchers)
+		}
+		r.rules = append(r.rules, compiled)
+	}
+	for _, severity := range config.AtAllSeverities {
+		r.atAllSeverities[severity] = true
+	}
+	return r, nil
+}
+
+// Resolve 返回一条告警需要 @ 的人，已恢复的告警不 @ 任何人
+func (r *MentionResolver) Resolve(alert models.Alert) models.Mentions {
+	var mentions models.Mentions
+	if alert.Status == "resolved" {
+		return mentions
+	}
+	for _, rule := range r.rules {
+		if !rule.matches(alert.Labels) {
+			continue
+		}
+		mentions.Mobiles = appendUnique(mentions.Mobiles, rule.mobiles...)
+		mentions.UserIDs = appendUnique(mentions.UserIDs, rule.userIDs...)
+	}
+	mentions.AtAll = r.atAllSeverities[alert.Labels["severity"]]
+	return mentions
+}
+
+// ResolveAll 返回每条告警需要 @ 的人 (按 fingerprint) 以及所有告警合并后的结果
+func (r *MentionResolver) ResolveAll(alerts []models.Alert) (models.Mentions, map[string]models.Mentions) {
+	var all models.Mentions
+	byFingerprint := make(map[string]models.Mentions, len(alerts))
+	for _, alert := range alerts {
+		mentions := r.Resolve(alert)
+		byFingerprint[alert.Fingerprint] = mentions
+		all.Mobiles = appendUnique(all.Mobiles, mentions.Mobiles...)
+		all.UserIDs = appendUnique(all.UserIDs, mentions.UserIDs...)
+		all.AtAll = all.AtAll || mentions.AtAll
+	}
+	return all, byFingerprint
+}
+
+func (rule mentionRule) matches(labels map[string]string) bool {
+	for _, m := range rule.matchers {
+		if !m.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// appendUnique 追加 values 中还不在 list 里的值，保持原有顺序
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
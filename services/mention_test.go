@@ -0,0 +1,96 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"prometheus-webhook/models"
+)
+
+func TestMentionResolver(t *testing.T) {
+	resolver, err := NewMentionResolver(models.MentionConfig{
+		AtAllSeverities: []string{"critical"},
+		Rules: []models.MentionRule{
+			{Matchers: []string{`team="dba"`}, UserIDs: []string{"ou_dba"}, Mobiles: []string{"138"}},
+			{Matchers: []string{`team="dba"`, `severity=~"critical|warning"`}, UserIDs: []string{"ou_dba", "ou_lead"}},
+			{Matchers: []string{`owner="zhangsan"`}, UserIDs: []string{"ou_zhangsan"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewMentionResolver() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		alert models.Alert
+		want  models.Mentions
+	}{
+		{
+			name:  "多条规则匹配时合并去重",
+			alert: models.Alert{Status: "firing", Labels: map[string]string{"team": "dba", "severity": "warning"}},
+			want:  models.Mentions{Mobiles: []string{"138"}, UserIDs: []string{"ou_dba", "ou_lead"}},
+		},
+		{
+			name:  "severity 在 at_all_severities 中时 @所有人",
+			alert: models.Alert{Status: "firing", Labels: map[string]string{"owner": "zhangsan", "severity": "critical"}},
+			want:  models.Mentions{UserIDs: []string{"ou_zhangsan"}, AtAll: true},
+		},
+		{
+			name:  "没有匹配的规则",
+			alert: models.Alert{Status: "firing", Labels: map[string]string{"team": "web"}},
+			want:  models.Mentions{},
+		},
+		{
+			name:  "已恢复的告警不 @ 任何人",
+			alert: models.Alert{Status: "resolved", Labels: map[string]string{"team": "dba", "severity": "critical"}},
+			want:  models.Mentions{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolver.Resolve(tt.alert); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Resolve() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMentionResolverResolveAll(t *testing.T) {
+	resolver, err := NewMentionResolver(models.MentionConfig{
+		AtAllSeverities: []string{"critical"},
+		Rules: []models.MentionRule{
+			{Matchers: []string{`team="dba"`}, UserIDs: []string{"ou_dba"}},
+			{Matchers: []string{`team="web"`}, UserIDs: []string{"ou_web"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, byFingerprint := resolver.ResolveAll([]models.Alert{
+		{Fingerprint: "a", Status: "firing", Labels: map[string]string{"team": "dba"}},
+		{Fingerprint: "b", Status: "firing", Labels: map[string]string{"team": "web", "severity": "critical"}},
+		{Fingerprint: "c", Status: "resolved", Labels: map[string]string{"team": "ops", "severity": "critical"}},
+	})
+	if want := (models.Mentions{UserIDs: []string{"ou_dba", "ou_web"}, AtAll: true}); !reflect.DeepEqual(all, want) {
+		t.Errorf("all = %+v, want %+v", all, want)
+	}
+	if got := byFingerprint["a"]; !reflect.DeepEqual(got, models.Mentions{UserIDs: []string{"ou_dba"}}) {
+		t.Errorf("byFingerprint[a] = %+v", got)
+	}
+	if got := byFingerprint["c"]; !got.Empty() {
+		t.Errorf("byFingerprint[c] = %+v, want no mentions for a resolved alert", got)
+	}
+}
+
+func TestNewMentionResolverErrors(t *testing.T) {
+	tests := map[string]models.MentionConfig{
+		"没有 @ 对象": {Rules: []models.MentionRule{{Matchers: []string{`team="dba"`}}}},
+		"匹配条件无效":  {Rules: []models.MentionRule{{Matchers: []string{`team`}, UserIDs: []string{"ou_dba"}}}},
+	}
+	for name, config := range tests {
+		if _, err := NewMentionResolver(config); err == nil {
+			t.Errorf("%s: NewMentionResolver() error = nil", name)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"prometheus-webhook/models"
+)
+
+// MatchType 标签匹配方式
+type MatchType string
+
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+// Matcher 单个标签匹配条件
+type Matcher struct {
+	Name  string
+	Type  MatchType
+	Value string
+	re    *regexp.Regexp
+}
+
+var matcherPattern = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_]*)\s*(=~|!~|!=|=)\s*(.*?)\s*$`)
+
+// ParseMatcher 解析形如 name="value", name!="value", name=~"regex", name!~"regex" 的匹配条件
+func ParseMatcher(s string) (*Matcher, error) {
+	parts := matcherPattern.FindStringSubmatch(s)
+	if parts == nil {
+		return nil, fmt.Errorf("无效的匹配条件: %s", s)
+	}
+
+	value := parts[3]
+	if strings.HasPrefix(value, `"`) {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("无效的匹配条件值 %s: %w", s, err)
+		}
+		value = unquoted
+	}
+
+	m := &Matcher{Name: parts[1], Type: MatchType(parts[2]), Value: value}
+	if m.Type == MatchRegexp || m.Type == MatchNotRegexp {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("无效的正则表达式 %s: %w", s, err)
+		}
+		m.re = re
+	}
+	return m, nil
+}
+
+// Matches 判断标签集合是否满足匹配条件，缺失的标签按空字符串处理
+func (m *Matcher) Matches(labels map[string]string) bool {
+	value := labels[m.Name]
+	switch m.Type {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	}
+	return false
+}
+
+type routeNode struct {
+	receiver string
+	matchers []*Matcher
+	cont     bool
+	children []*routeNode
+}
+
+// AlertRouter 根据路由树将告警分发到接收器
+type AlertRouter struct {
+	routes []*routeNode
+}
+
+// RoutedWebhook 分配给某个接收器的告警子集
+type RoutedWebhook struct {
+	Receiver string
+	Webhook  models.AlertmanagerWebhook
+}
+
+func NewAlertRouter(routes []models.Route) (*AlertRouter, error) {
+	nodes, err := compileRoutes(routes)
+	if err != nil {
+		return nil, err
+	}
+	return &AlertRouter{routes: nodes}, nil
+}
+
+func compileRoutes(routes []models.Route) ([]*routeNode, error) {
+	var nodes []*routeNode
+	for _, route := range routes {
+		node := &routeNode{receiver: route.Receiver, cont: route.Continue}
+		for _, s := range route.Matchers {
+			m, err := ParseMatcher(s)
+			if err != nil {
+				return nil, err
+			}
+			node.matchers = append(node.matchers, m)
+		}
+		children, err := compileRoutes(route.Routes)
+		if err != nil {
+			return nil, err
+		}
+		node.children = children
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// Route 将 webhook 中的每条告警按路由树分配给接收器，返回按接收器拆分后的 webhook
+func (r *AlertRouter) Route(webhook models.AlertmanagerWebhook) []RoutedWebhook {
+	var order []string
+	alertsByReceiver := make(map[string][]models.Alert)
+
+	for _, alert := range webhook.Alerts {
+		labels := mergeLabels(webhook.GroupLabels, webhook.CommonLabels, alert.Labels)
+
+		seen := make(map[string]bool)
+		for _, receiver := range matchRoutes(r.routes, labels, "") {
+			if seen[receiver] {
+				continue
+			}
+			seen[receiver] = true
+
+			if _, ok := alertsByReceiver[receiver]; !ok {
+				order = append(order, receiver)
+			}
+			alertsByReceiver[receiver] = append(alertsByReceiver[receiver], alert)
+		}
+	}
+
+	var routed []RoutedWebhook
+	for _, receiver := range order {
+		routed = append(routed, RoutedWebhook{
+			Receiver: receiver,
+			Webhook:  SubWebhook(webhook, alertsByReceiver[receiver]),
+		})
+	}
+	return routed
+}
+
+// matchRoutes 返回命中的接收器列表，没有配置 receiver 的节点继承父节点的接收器 inherited；
+// 命中但得不到接收器的节点视为未命中，继续匹配后续的同级路由，避免告警被丢弃
+func matchRoutes(nodes []*routeNode, labels map[string]string, inherited string) []string {
+	var receivers []string
+	for _, node := range nodes {
+		if !node.matches(labels) {
+			continue
+		}
+
+		receiver := node.receiver
+		if receiver == "" {
+			receiver = inherited
+		}
+		matched := matchRoutes(node.children, labels, receiver)
+		if len(matched) == 0 && receiver != "" {
+			matched = []string{receiver}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		receivers = append(receivers, matched...)
+
+		if !node.cont {
+			break
+		}
+	}
+	return receivers
+}
+
+func (n *routeNode) matches(labels map[string]string) bool {
+	for _, m := range n.matchers {
+		if !m.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func mergeLabels(sets ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, set := range sets {
+		for k, v := range set {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// SubWebhook 基于告警子集构造新的 webhook，并重新计算状态和公共标签
+func SubWebhook(webhook models.AlertmanagerWebhook, alerts []models.Alert) models.AlertmanagerWebhook {
+	sub := webhook
+	sub.Alerts = alerts
+	sub.Status = "resolved"
+	for _, alert := range alerts {
+		if alert.Status == "firing" {
+			sub.Status = "firing"
+			break
+		}
+	}
+
+	labelSets := make([]map[string]string, 0, len(alerts))
+	annotationSets := make([]map[string]string, 0, len(alerts))
+	for _, alert := range alerts {
+		labelSets = append(labelSets, alert.Labels)
+		annotationSets = append(annotationSets, alert.Annotations)
+	}
+	sub.CommonLabels = commonPairs(labelSets)
+	sub.CommonAnnotations = commonPairs(annotationSets)
+	return sub
+}
+
+func commonPairs(sets []map[string]string) map[string]string {
+	common := make(map[string]string)
+	if len(sets) == 0 {
+		return common
+	}
+	for k, v := range sets[0] {
+		common[k] = v
+	}
+	for _, set := range sets[1:] {
+		for k, v := range common {
+			if set[k] != v {
+				delete(common, k)
+			}
+		}
+	}
+	return common
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"prometheus-webhook/models"
+)
+
+func TestParseMatcher(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Matcher
+		wantErr bool
+	}{
+		{input: `severity="critical"`, want: Matcher{Name: "severity", Type: MatchEqual, Value: "critical"}},
+		{input: ` env != "dev" `, want: Matcher{Name: "env", Type: MatchNotEqual, Value: "dev"}},
+		{input: `team=~"db|dba"`, want: Matcher{Name: "team", Type: MatchRegexp, Value: "db|dba"}},
+		{input: `team!~"web.*"`, want: Matcher{Name: "team", Type: MatchNotRegexp, Value: "web.*"}},
+		{input: `team=dba`, want: Matcher{Name: "team", Type: MatchEqual, Value: "dba"}},
+		{input: `team=`, want: Matcher{Name: "team", Type: MatchEqual, Value: ""}},
+		{input: `msg="say \"hi\""`, want: Matcher{Name: "msg", Type: MatchEqual, Value: `say "hi"`}},
+		{input: `severity`, wantErr: true},
+		{input: `1abc="x"`, wantErr: true},
+		{input: `team=~"(db"`, wantErr: true},
+		{input: `team="unterminated`, wantErr: true},
+	}
+	for _, tt := range tests {
+		m, err := ParseMatcher(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseMatcher(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if m.Name != tt.want.Name || m.Type != tt.want.Type || m.Value != tt.want.Value {
+			t.Errorf("ParseMatcher(%q) = %+v, want %+v", tt.input, *m, tt.want)
+		}
+	}
+}
+
+func TestMatcherMatches(t *testing.T) {
+	labels := map[string]string{"team": "dba", "env": "prod"}
+	tests := []struct {
+		matcher string
+		want    bool
+	}{
+		{`team="dba"`, true},
+		{`team="db"`, false},
+		{`team=~"db|dba"`, true},
+		{`team=~"db"`, false}, // 正则需要完整匹配
+		{`team!~"web.*"`, true},
+		{`env!="dev"`, true},
+		{`missing=""`, true}, // 缺失的标签按空字符串处理
+		{`missing!=""`, false},
+	}
+	for _, tt := range tests {
+		m, err := ParseMatcher(tt.matcher)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := m.Matches(labels); got != tt.want {
+			t.Errorf("%s.Matches() = %v, want %v", tt.matcher, got, tt.want)
+		}
+	}
+}
+
+func TestMatchRoutes(t *testing.T) {
+	routes := []models.Route{
+		{Receiver: "critical", Matchers: []string{`severity="critical"`}, Continue: true},
+		{
+			Receiver: "dba",
+			Matchers: []string{`team=~"db|dba"`},
+			Routes: []models.Route{
+				{Receiver: "dba-dev", Matchers: []string{`env="dev"`}},
+			},
+		},
+		{
+			// 没有 receiver 的节点: 子路由继承父节点的接收器，没有子路由命中时继续匹配后续路由
+			Matchers: []string{`team="web"`},
+			Routes: []models.Route{
+				{Receiver: "web-prod", Matchers: []string{`env="prod"`}},
+			},
+		},
+		{
+			Receiver: "ops",
+			Matchers: []string{`team="ops"`},
+			Routes: []models.Route{
+				// 继承 ops
+				{Matchers: []string{`env="prod"`}, Routes: []models.Route{{Receiver: "ops-pager", Matchers: []string{`pager="true"`}}}},
+			},
+		},
+		{Receiver: "default"},
+	}
+	router, err := NewAlertRouter(routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   []string
+	}{
+		{name: "默认路由", labels: map[string]string{"team": "app"}, want: []string{"default"}},
+		{name: "命中第一个路由后停止", labels: map[string]string{"team": "dba"}, want: []string{"dba"}},
+		{name: "子路由优先", labels: map[string]string{"team": "dba", "env": "dev"}, want: []string{"dba-dev"}},
+		{name: "continue 继续匹配", labels: map[string]string{"severity": "critical", "team": "dba"}, want: []string{"critical", "dba"}},
+		{name: "continue 后落到默认路由", labels: map[string]string{"severity": "critical"}, want: []string{"critical", "default"}},
+		{name: "无接收器节点的子路由命中", labels: map[string]string{"team": "web", "env": "prod"}, want: []string{"web-prod"}},
+		{name: "无接收器节点没有子路由命中时不丢弃告警", labels: map[string]string{"team": "web", "env": "dev"}, want: []string{"default"}},
+		{name: "继承父节点的接收器", labels: map[string]string{"team": "ops", "env": "prod"}, want: []string{"ops"}},
+		{name: "继承后的子路由命中", labels: map[string]string{"team": "ops", "env": "prod", "pager": "true"}, want: []string{"ops-pager"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchRoutes(router.routes, tt.labels, ""); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matchRoutes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteSplitsAlertsByReceiver(t *testing.T) {
+	router, err := NewAlertRouter([]models.Route{
+		{Receiver: "critical", Matchers: []string{`severity="critical"`}, Continue: true},
+		{Receiver: "dba", Matchers: []string{`team="dba"`}},
+		{Receiver: "default"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	webhook := models.AlertmanagerWebhook{
+		GroupKey:    "g",
+		Status:      "firing",
+		GroupLabels: map[string]string{"team": "dba"},
+		Alerts: []models.Alert{
+			{Status: "firing", Fingerprint: "a", Labels: map[string]string{"alertname": "Disk", "severity": "critical"}},
+			{Status: "resolved", Fingerprint: "b", Labels: map[string]string{"alertname": "Disk", "severity": "warning"}},
+		},
+	}
+	routed := router.Route(webhook)
+
+	got := make(map[string][]string)
+	var order []string
+	for _, r := range routed {
+		order = append(order, r.Receiver)
+		for _, alert := range r.Webhook.Alerts {
+			got[r.Receiver] = append(got[r.Receiver], alert.Fingerprint)
+		}
+	}
+	// groupLabels 参与匹配，两条告警都路由到 dba
+	if !reflect.DeepEqual(order, []string{"critical", "dba"}) {
+		t.Fatalf("receivers = %v, want [critical dba]", order)
+	}
+	if !reflect.DeepEqual(got["critical"], []string{"a"}) || !reflect.DeepEqual(got["dba"], []string{"a", "b"}) {
+		t.Errorf("alerts = %v", got)
+	}
+	if routed[0].Webhook.Status != "firing" || routed[0].Webhook.CommonLabels["severity"] != "critical" {
+		t.Errorf("critical webhook = %+v, want recomputed status and common labels", routed[0].Webhook)
+	}
+	if _, ok := routed[1].Webhook.CommonLabels["severity"]; ok {
+		t.Errorf("dba common labels = %v, want severity removed", routed[1].Webhook.CommonLabels)
+	}
+}
+
+func TestNewAlertRouterInvalidMatcher(t *testing.T) {
+	_, err := NewAlertRouter([]models.Route{
+		{Receiver: "a", Routes: []models.Route{{Receiver: "b", Matchers: []string{`team=~"("`}}}},
+	})
+	if err == nil {
+		t.Error("NewAlertRouter() error = nil, want error for an invalid nested matcher")
+	}
+}
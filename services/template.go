@@ -1,45 +1,229 @@
 package services
 
 import (
-	"log"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log/slog"
+	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"text/template"
 	"time"
+
+	"prometheus-webhook/internal/metrics"
 )
 
 type TemplateService struct {
-	templates map[string]*template.Template
+	templates map[string]*templateEntry
 	location  *time.Location
 	mu        sync.RWMutex
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// templateEntry 一个模板文件的缓存和加载状态
+type templateEntry struct {
+	tmpl     *template.Template // 最近一次加载成功的模板，从未加载成功时为 nil
+	checksum [sha256.Size]byte  // 最近一次尝试加载的文件内容
+	loadedAt time.Time
+	err      error // 最近一次加载失败的原因，之后加载成功时清空
+	failedAt time.Time
+}
+
+// TemplateStatus 模板文件的加载状态
+type TemplateStatus struct {
+	Path      string     `json:"path"`
+	Loaded    bool       `json:"loaded"`
+	LoadedAt  *time.Time `json:"loaded_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	FailedAt  *time.Time `json:"failed_at,omitempty"`
 }
 
 func NewTemplateService(location *time.Location) *TemplateService {
 	return &TemplateService{
-		templates: make(map[string]*template.Template),
+		templates: make(map[string]*templateEntry),
 		location:  location,
+		stop:      make(chan struct{}),
 	}
 }
 
+// Location 返回模板中格式化时间使用的时区
+func (s *TemplateService) Location() *time.Location {
+	return s.location
+}
+
+// MessageTemplateName 返回模板文件中用于渲染消息的子模板名称，例如 feishu.tmpl 对应 feishu_message
+func MessageTemplateName(templatePath string) string {
+	templateBaseName := filepath.Base(templatePath)
+	return strings.TrimSuffix(templateBaseName, filepath.Ext(templateBaseName)) + "_message"
+}
+
 // GetTemplate 按需加载、缓存并返回模板
+// 模板文件被修改后如果无法解析，继续返回最近一次加载成功的模板
 func (s *TemplateService) GetTemplate(templatePath string) (*template.Template, error) {
 	s.mu.RLock()
-	tmpl, ok := s.templates[templatePath]
+	entry, ok := s.templates[templatePath]
 	s.mu.RUnlock()
-	if ok {
-		return tmpl, nil
+	if ok && entry.tmpl != nil {
+		return entry.tmpl, nil
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	// 再次检查以防并发加载
-	if tmpl, ok = s.templates[templatePath]; ok {
-		return tmpl, nil
+	if entry, ok = s.templates[templatePath]; ok && entry.tmpl != nil {
+		return entry.tmpl, nil
+	}
+
+	data, err := os.ReadFile(templatePath)
+	if err != nil {
+		s.recordFailure(templatePath, [sha256.Size]byte{}, err)
+		return nil, err
+	}
+	checksum := sha256.Sum256(data)
+	newTmpl, err := s.parse(templatePath, data)
+	if err != nil {
+		s.recordFailure(templatePath, checksum, err)
+		return nil, err
+	}
+
+	s.templates[templatePath] = &templateEntry{tmpl: newTmpl, checksum: checksum, loadedAt: time.Now()}
+	slog.Info("模板加载成功", "template", templatePath)
+	return newTmpl, nil
+}
+
+// Status 返回模板文件的加载状态
+func (s *TemplateService) Status(templatePath string) TemplateStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := TemplateStatus{Path: templatePath}
+	entry, ok := s.templates[templatePath]
+	if !ok {
+		return status
+	}
+	if entry.tmpl != nil {
+		status.Loaded = true
+		loadedAt := entry.loadedAt
+		status.LoadedAt = &loadedAt
+	}
+	if entry.err != nil {
+		status.LastError = entry.err.Error()
+		failedAt := entry.failedAt
+		status.FailedAt = &failedAt
+	}
+	return status
+}
+
+// Watch 每隔 interval 检查已加载的模板文件，内容变化时重新解析，直到调用 Close
+func (s *TemplateService) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			paths := make([]string, 0, len(s.templates))
+			for path := range s.templates {
+				paths = append(paths, path)
+			}
+			s.mu.RUnlock()
+
+			for _, path := range paths {
+				s.reload(path)
+			}
+		}
+	}
+}
+
+// Close 停止检查模板文件
+func (s *TemplateService) Close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// reload 模板文件内容变化时重新解析，解析成功后才替换缓存中的模板
+func (s *TemplateService) reload(templatePath string) {
+	data, err := os.ReadFile(templatePath)
+	if err != nil {
+		s.mu.Lock()
+		s.recordFailure(templatePath, [sha256.Size]byte{}, err)
+		s.mu.Unlock()
+		return
+	}
+	checksum := sha256.Sum256(data)
+
+	s.mu.RLock()
+	entry := s.templates[templatePath]
+	unchanged := entry != nil && entry.checksum == checksum
+	s.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	newTmpl, err := s.parse(templatePath, data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.recordFailure(templatePath, checksum, err)
+		metrics.TemplateReloads.WithLabelValues(templatePath, "failure").Inc()
+		slog.Warn("模板文件修改后无法解析, 继续使用修改前的模板", "template", templatePath, "error", err)
+		return
+	}
+	s.templates[templatePath] = &templateEntry{tmpl: newTmpl, checksum: checksum, loadedAt: time.Now()}
+	metrics.TemplateReloads.WithLabelValues(templatePath, "success").Inc()
+	slog.Info("模板文件发生变化, 已重新加载", "template", templatePath)
+}
+
+// parse 解析模板文件内容，并检查是否定义了用于渲染消息的子模板
+func (s *TemplateService) parse(templatePath string, data []byte) (*template.Template, error) {
+	tmpl, err := s.newTemplate(filepath.Base(templatePath)).Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if name := MessageTemplateName(templatePath); tmpl.Lookup(name) == nil {
+		return nil, fmt.Errorf("模板 %s 中没有定义 %s", templatePath, name)
 	}
+	return tmpl, nil
+}
+
+// recordFailure 记录加载失败，保留最近一次加载成功的模板，调用方需要持有写锁
+func (s *TemplateService) recordFailure(templatePath string, checksum [sha256.Size]byte, err error) {
+	entry, ok := s.templates[templatePath]
+	if !ok {
+		entry = &templateEntry{}
+		s.templates[templatePath] = entry
+	}
+	entry.checksum = checksum
+	entry.err = err
+	entry.failedAt = time.Now()
+}
+
+// RenderString 使用与模板文件相同的函数渲染一段内联模板，例如接收器配置中的请求头
+func (s *TemplateService) RenderString(name, text string, data interface{}) (string, error) {
+	tmpl, err := s.newTemplate(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
 
-	// 加载新模板
-	templateName := filepath.Base(templatePath)
-	newTmpl, err := template.New(templateName).Funcs(template.FuncMap{
+// newTemplate 创建注册了所有自定义函数的空模板
+func (s *TemplateService) newTemplate(name string) *template.Template {
+	var tmpl *template.Template
+	tmpl = template.New(name).Funcs(template.FuncMap{
 		"getCSTtime": s.getCSTtime,
 		"eq": func(a, b interface{}) bool {
 			return a == b
@@ -47,20 +231,60 @@ func (s *TemplateService) GetTemplate(templatePath string) (*template.Template,
 		"sub": func(a, b int) int {
 			return a - b
 		},
-	}).ParseFiles(templatePath)
-	if err != nil {
-		return nil, err
-	}
-
-	s.templates[templatePath] = newTmpl
-	log.Printf("模板 %s 加载成功", templatePath)
-	return newTmpl, nil
+		"replace": func(s, old, new string) string {
+			return strings.ReplaceAll(s, old, new)
+		},
+		"escapeHTML":       escapeHTML,
+		"escapeMarkdownV2": escapeMarkdownV2,
+		"toJSON":           toJSON,
+		// include 执行同一模板文件中的子模板并返回结果，便于配合 toJSON 使用
+		"include": func(name string, data interface{}) (string, error) {
+			var buf bytes.Buffer
+			if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+				return "", err
+			}
+			return buf.String(), nil
+		},
+	})
+	return tmpl
 }
 
 func (s *TemplateService) getCSTtime(t time.Time) string {
 	return t.In(s.location).Format("2006-01-02 15:04:05")
 }
 
+// markdownV2Replacer 转义 Telegram MarkdownV2 中的所有保留字符
+var markdownV2Replacer = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// escapeMarkdownV2 与 escapeHTML 接收 interface{}，使缺失的标签或注解可以直接传入并得到空字符串
+func escapeMarkdownV2(v interface{}) string {
+	return markdownV2Replacer.Replace(templateString(v))
+}
+
+func escapeHTML(v interface{}) string {
+	return html.EscapeString(templateString(v))
+}
+
+// toJSON 将值编码为 JSON，字符串会被编码为带引号并正确转义的 JSON 字符串
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func templateString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
 func SetTimezone(timezone string) (*time.Location, error) {
 	return time.LoadLocation(timezone)
 }
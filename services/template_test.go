@@ -0,0 +1,76 @@
+package services
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func render(t *testing.T, s *TemplateService, path string) string {
+	t.Helper()
+	tmpl, err := s.GetTemplate(path)
+	if err != nil {
+		t.Fatalf("GetTemplate() error = %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, MessageTemplateName(path), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestTemplateReloadKeepsLastGood(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ops.tmpl")
+	writeFile(t, path, `{{ define "ops_message" }}v1{{ end }}`)
+	s := NewTemplateService(time.UTC)
+	if got := render(t, s, path); got != "v1" {
+		t.Fatalf("render = %q, want v1", got)
+	}
+
+	// 修改后无法解析时继续使用修改前的模板，并记录错误
+	writeFile(t, path, `{{ define "ops_message" }}{{ .status {{ end }}`)
+	s.reload(path)
+	if got := render(t, s, path); got != "v1" {
+		t.Errorf("render = %q after a broken edit, want the last good template", got)
+	}
+	status := s.Status(path)
+	if !status.Loaded || status.LastError == "" || status.FailedAt == nil {
+		t.Errorf("Status() = %+v, want loaded with LastError", status)
+	}
+
+	// 缺少消息子模板同样视为加载失败
+	writeFile(t, path, `{{ define "other" }}v2{{ end }}`)
+	s.reload(path)
+	if got := render(t, s, path); got != "v1" {
+		t.Errorf("render = %q without ops_message, want the last good template", got)
+	}
+
+	writeFile(t, path, `{{ define "ops_message" }}v3{{ end }}`)
+	s.reload(path)
+	if got := render(t, s, path); got != "v3" {
+		t.Errorf("render = %q after fixing the template, want v3", got)
+	}
+	if status := s.Status(path); status.LastError != "" {
+		t.Errorf("Status().LastError = %q after a successful reload", status.LastError)
+	}
+}
+
+func TestTemplateStatusNeverLoaded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.tmpl")
+	s := NewTemplateService(time.UTC)
+	if _, err := s.GetTemplate(path); err == nil {
+		t.Fatal("GetTemplate() of a missing file succeeded")
+	}
+	if status := s.Status(path); status.Loaded || status.LastError == "" {
+		t.Errorf("Status() = %+v, want not loaded with LastError", status)
+	}
+}